@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var (
+	FlagAddAppend = false
+	FlagAddForce  = false
+	FlagAddTitle  = ""
+)
+
+// addContents returns contents which we write to the snippet. If the title is not empty, it's prepended as
+// a heading. contents always end with a line break.
+func addContents(input []byte, title string) []byte {
+	var b bytes.Buffer
+	if title != "" {
+		b.WriteString("# " + title + "\n\n")
+	}
+
+	b.Write(input)
+	if len(input) != 0 && input[len(input)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// confirmOverwrite asks the user whether to overwrite an existing snippet. stdin is the snippet's contents,
+// so we ask the user through the terminal.
+func confirmOverwrite(name string) (bool, error) {
+	tty, err := openTerminal()
+	if err != nil {
+		return false, fmt.Errorf("snippet %s exists, use --force to overwrite it or --append to append to it: %w", name, os.ErrExist)
+	}
+	defer tty.Close()
+
+	return ConfirmPrompt(tty, tty, fmt.Sprintf("File %s already exists, overwrite it? (y/n) [n] ", name))
+}
+
+// writeSnippet writes the contents to the snippet file or appends them to it. It makes parent directories too.
+func writeSnippet(fpath string, contents []byte, appendTo bool) error {
+	if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+		return fmt.Errorf("can not create snippet directory: %w", err)
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+
+		// Start appended contents from a new line.
+		if b, err := os.ReadFile(fpath); err == nil && len(b) != 0 && b[len(b)-1] != '\n' {
+			contents = append([]byte("\n"), contents...)
+		}
+	}
+
+	f, err := os.OpenFile(fpath, flag, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(contents); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestAddContents(t *testing.T) {
+	assertEqual(t, string(addContents([]byte("ls -la"), "")), "ls -la\n")
+	assertEqual(t, string(addContents([]byte("ls -la\n"), "List files")), "# List files\n\nls -la\n")
+}
+
+func TestConfirmOverwrite(t *testing.T) {
+	term := fakeTerminal(t, "n\n")
+	ok, err := confirmOverwrite("a")
+	assertEqual(t, err, nil)
+	assertTrue(t, !ok)
+	assertEqual(t, term.String(), "File a already exists, overwrite it? (y/n) [n] ")
+
+	// It defaults to no.
+	fakeTerminal(t, "\n")
+	ok, err = confirmOverwrite("a")
+	assertEqual(t, err, nil)
+	assertTrue(t, !ok)
+	fakeTerminal(t, "y\n")
+	ok, err = confirmOverwrite("a")
+	assertEqual(t, err, nil)
+	assertTrue(t, ok)
+
+	openTerminal = func() (io.ReadWriteCloser, error) {
+		return nil, os.ErrNotExist
+	}
+	_, err = confirmOverwrite("a")
+	assertTrue(t, errors.Is(err, os.ErrExist))
+}
+
+func TestWriteSnippet(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "a/b.md")
+
+	assertEqual(t, writeSnippet(fpath, []byte("abc"), false), nil)
+	assertEqual(t, writeSnippet(fpath, []byte("def\n"), true), nil)
+	b, err := os.ReadFile(fpath)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(b), "abc\ndef\n")
+
+	assertEqual(t, writeSnippet(fpath, []byte("ghi\n"), false), nil)
+	b, err = os.ReadFile(fpath)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(b), "ghi\n")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var FlagViewBlock = ""
+
+// splitBlockAnchor splits a snippet name like "git/rebase#2" into the snippet name and the block anchor.
+// If a snippet with the whole name exists, the name doesn't have any anchor.
+func splitBlockAnchor(name string) (string, string) {
+	i := strings.LastIndex(name, "#")
+	if i == -1 {
+		return name, ""
+	}
+
+	if _, err := os.Stat(Cfg.SnippetPath(name)); err == nil {
+		return name, ""
+	}
+	return name[:i], name[i+1:]
+}
+
+// matchBlock reports whether the code block (at the index, starting from 1) matches the anchor. an anchor
+// can be the block index, its info string or a word of it (e.g., "squash" in "bash squash" or
+// "bash title=squash"), or its heading.
+func matchBlock(b codeBlock, index int, anchor string) bool {
+	if anchor == strconv.Itoa(index) || strings.EqualFold(anchor, b.Info) {
+		return true
+	}
+
+	for _, word := range strings.Fields(b.Info) {
+		_, value, _ := strings.Cut(word, "=")
+		if strings.EqualFold(anchor, word) || strings.EqualFold(anchor, strings.Trim(value, `"'`)) {
+			return true
+		}
+	}
+
+	return b.Heading != "" && (strings.EqualFold(anchor, b.Heading) || strings.EqualFold(anchor, headingSlug(b.Heading)))
+}
+
+// findBlock returns the first code block which matches the anchor.
+func findBlock(blocks []codeBlock, anchor string) (codeBlock, int, error) {
+	for i, b := range blocks {
+		if matchBlock(b, i+1, anchor) {
+			return b, i + 1, nil
+		}
+	}
+	return codeBlock{}, 0, invalidArgument("code block %q not found, the snippet has %d code block(s)", anchor, len(blocks))
+}
+
+// readSnippetBlocks reads code blocks of a markdown snippet.
+func readSnippetBlocks(fpath string) ([]codeBlock, error) {
+	if !isMarkdown(fpath) {
+		return nil, invalidArgument("%s is not a markdown snippet, only markdown snippets have code blocks", fpath)
+	}
+
+	b, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	_, body, err := parseSnippet(fpath, b)
+	if err != nil {
+		return nil, err
+	}
+	return markdownCodeBlocks(string(body)), nil
+}
+
+// blockAnchors returns anchors of the code blocks which match the filter with their descriptions (in the
+// auto-completion format). Each block has an index anchor, and its heading and info string anchors if they
+// are unique, so they find the same block. A nil filter matches all blocks.
+func blockAnchors(blocks []codeBlock, filter func(b codeBlock) bool) []string {
+	counts := make(map[string]int)
+	for _, b := range blocks {
+		for _, anchor := range namedAnchors(b) {
+			counts[strings.ToLower(anchor)]++
+		}
+	}
+
+	var res []string
+	for i, b := range blocks {
+		if filter != nil && !filter(b) {
+			continue
+		}
+
+		firstLine, _, _ := strings.Cut(strings.TrimSpace(b.Code), "\n")
+		desc := firstLine
+		if b.Lang() != "" {
+			desc = b.Lang() + ": " + firstLine
+		}
+		res = append(res, fmt.Sprintf("%d\t%s", i+1, desc))
+
+		for _, anchor := range namedAnchors(b) {
+			if counts[strings.ToLower(anchor)] == 1 {
+				res = append(res, fmt.Sprintf("%s\t%s", anchor, desc))
+			}
+		}
+	}
+	return res
+}
+
+// namedAnchors returns anchors of the code block other than its index: its heading slug and words of its info
+// string (and their values, e.g., "title=squash" and "squash"). The language isn't an anchor, many blocks have it.
+func namedAnchors(b codeBlock) []string {
+	var res []string
+	add := func(anchor string) {
+		if _, err := strconv.Atoi(anchor); err == nil || anchor == "" {
+			return // It would be an index anchor.
+		}
+		if !slices.ContainsFunc(res, func(v string) bool { return strings.EqualFold(v, anchor) }) {
+			res = append(res, anchor)
+		}
+	}
+
+	add(headingSlug(b.Heading))
+	for i, word := range strings.Fields(b.Info) {
+		if i == 0 {
+			continue
+		}
+		add(word)
+		if _, value, ok := strings.Cut(word, "="); ok {
+			add(strings.Trim(value, `"'`))
+		}
+	}
+	return res
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+var blockTestText = "# Rebase\n```bash\ngit rebase -i\n```\n## Squash commits\n```bash title=squash\ngit reset --soft HEAD~2\n```\n```\nplain\n```\n"
+
+func TestSplitBlockAnchor(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir}
+	makeTree(t, tmpDir, "c#1.md")
+
+	cases := []struct {
+		tag    string
+		name   string
+		res    string
+		anchor string
+	}{
+		{tag: "t1", name: "a/b", res: "a/b"},
+		{tag: "t2", name: "a/b#2", res: "a/b", anchor: "2"},
+		{tag: "t3", name: "a#b#squash", res: "a#b", anchor: "squash"},
+		{tag: "t4", name: "c#1", res: "c#1"},
+		{tag: "t5", name: "a#", res: "a"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			res, anchor := splitBlockAnchor(c.name)
+			assertEqual(t, res, c.res)
+			assertEqual(t, anchor, c.anchor)
+		})
+	}
+}
+
+func TestFindBlock(t *testing.T) {
+	blocks := markdownCodeBlocks(blockTestText)
+
+	cases := []struct {
+		tag    string
+		anchor string
+		index  int
+	}{
+		{tag: "t1", anchor: "1", index: 1},
+		{tag: "t2", anchor: "3", index: 3},
+		{tag: "t3", anchor: "squash", index: 2},
+		{tag: "t4", anchor: "squash-commits", index: 2},
+		{tag: "t5", anchor: "Squash Commits", index: 2},
+		{tag: "t6", anchor: "bash", index: 1},
+		{tag: "t7", anchor: "rebase", index: 1},
+		{tag: "t8", anchor: "4"},
+		{tag: "t9", anchor: "other"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			b, index, err := findBlock(blocks, c.anchor)
+			assertEqual(t, index, c.index)
+			assertEqual(t, err != nil, c.index == 0)
+			if c.index != 0 {
+				assertEqual(t, b, blocks[c.index-1])
+			}
+		})
+	}
+}
+
+func TestReadSnippetBlocks(t *testing.T) {
+	tmpDir := t.TempDir()
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "a.md"), []byte("---\ntitle: a\n---\n"+blockTestText), 0644), nil)
+
+	blocks, err := readSnippetBlocks(path.Join(tmpDir, "a.md"))
+	assertEqual(t, err, nil)
+	assertEqual(t, len(blocks), 3)
+	assertEqual(t, blocks[0].Line, 2)
+
+	_, err = readSnippetBlocks(path.Join(tmpDir, "a.yaml"))
+	assertTrue(t, err != nil)
+	_, err = readSnippetBlocks(path.Join(tmpDir, "b.md"))
+	assertTrue(t, err != nil)
+}
+
+func TestBlockAnchors(t *testing.T) {
+	blocks := markdownCodeBlocks(blockTestText)
+	assertEqualSlice(t, blockAnchors(blocks, nil), []string{
+		"1\tbash: git rebase -i",
+		"rebase\tbash: git rebase -i",
+		"2\tbash: git reset --soft HEAD~2",
+		"title=squash\tbash: git reset --soft HEAD~2",
+		"squash\tbash: git reset --soft HEAD~2",
+		"3\tplain",
+	})
+	assertEqualSlice(t, blockAnchors(blocks, isShellBlock), []string{
+		"1\tbash: git rebase -i",
+		"rebase\tbash: git rebase -i",
+		"2\tbash: git reset --soft HEAD~2",
+		"title=squash\tbash: git reset --soft HEAD~2",
+		"squash\tbash: git reset --soft HEAD~2",
+	})
+
+	// Anchors which match more than one block aren't completed.
+	blocks = markdownCodeBlocks("```sh push\na\n```\n```sh title=push 2\nb\n```\n")
+	assertEqualSlice(t, blockAnchors(blocks, nil), []string{"1\tsh: a", "2\tsh: b", "title=push\tsh: b"})
+	for _, anchor := range []string{"1", "2", "title=push"} {
+		_, _, err := findBlock(blocks, anchor)
+		assertEqual(t, err, nil)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+)
+
+// Clipboard backends
+const (
+	ClipboardOSC52   = "osc52"
+	ClipboardCommand = "command"
+)
+
+var (
+	FlagCopy      = false
+	FlagCopyBlock = ""
+)
+
+// osc52Sequence returns the OSC 52 escape sequence which asks the terminal to put the text on the clipboard.
+// It works over SSH too. Inside tmux, the sequence is wrapped by the tmux passthrough sequence.
+func osc52Sequence(text string, tmux bool) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if tmux {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	return seq
+}
+
+// copyToClipboard puts the text on the clipboard using the clipboard command if it's configured, otherwise
+// using the OSC 52 escape sequence. It returns the used backend.
+func copyToClipboard(text string) (string, error) {
+	if len(Cfg.ClipboardCMD) != 0 {
+		cmd := Command(Cfg.ClipboardCMD[0], Cfg.ClipboardCMD[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return ClipboardCommand, cmd.Run()
+	}
+
+	// If we don't have access to the terminal, stderr is probably still the terminal.
+	var w io.Writer = os.Stderr
+	if tty, err := openTerminal(); err == nil {
+		defer tty.Close()
+		w = tty
+	}
+
+	_, err := io.WriteString(w, osc52Sequence(text, os.Getenv("TMUX") != ""))
+	return ClipboardOSC52, err
+}
+
+// snippetText returns the snippet's body without its front-matter, or raw code of one of its code blocks
+// if the anchor is not empty.
+func snippetText(name string, anchor string) (string, error) {
+	fpath := Cfg.SnippetPath(name)
+	if anchor != "" {
+		blocks, err := readSnippetBlocks(fpath)
+		if err != nil {
+			return "", err
+		}
+
+		b, _, err := findBlock(blocks, anchor)
+		return b.Code, err
+	}
+
+	b, err := os.ReadFile(fpath)
+	if err != nil {
+		return "", err
+	}
+
+	_, body, err := parseSnippet(fpath, b)
+	return string(body), err
+}
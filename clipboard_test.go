@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestOSC52Sequence(t *testing.T) {
+	assertEqual(t, osc52Sequence("echo hi", false), "\x1b]52;c;ZWNobyBoaQ==\a")
+	assertEqual(t, osc52Sequence("echo hi", true), "\x1bPtmux;\x1b\x1b]52;c;ZWNobyBoaQ==\a\x1b\\")
+}
+
+func TestCopyToClipboard(t *testing.T) {
+	t.Setenv("TMUX", "")
+	term := fakeTerminal(t, "")
+	Cfg = &Config{}
+
+	backend, err := copyToClipboard("abc")
+	assertEqual(t, err, nil)
+	assertEqual(t, backend, ClipboardOSC52)
+	assertEqual(t, term.String(), "\x1b]52;c;YWJj\a")
+
+	out := path.Join(t.TempDir(), "clipboard")
+	Cfg.ClipboardCMD = []string{"sh", "-c", "cat > " + out}
+	backend, err = copyToClipboard("abc")
+	assertEqual(t, err, nil)
+	assertEqual(t, backend, ClipboardCommand)
+	b, err := os.ReadFile(out)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(b), "abc")
+
+	Cfg.ClipboardCMD = []string{"false"}
+	_, err = copyToClipboard("abc")
+	assertTrue(t, err != nil)
+}
+
+func TestSnippetText(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir}
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "a.md"), []byte("---\ntitle: a\n---\n"+blockTestText), 0644), nil)
+
+	res, err := snippetText("a", "")
+	assertEqual(t, err, nil)
+	assertEqual(t, res, blockTestText)
+
+	res, err = snippetText("a", "squash")
+	assertEqual(t, err, nil)
+	assertEqual(t, res, "git reset --soft HEAD~2\n")
+
+	_, err = snippetText("b", "")
+	assertTrue(t, err != nil)
+
+	// The whole multi-document YAML snippet is copied.
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "ns.yaml"), []byte(multiDocYAML), 0644), nil)
+	res, err = snippetText("ns.yaml", "")
+	assertEqual(t, err, nil)
+	assertEqual(t, res, multiDocYAML)
+}
@@ -6,12 +6,15 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 )
 
 const prefix = "SNIP" // Env prefix.
 
+var FlagConfigFile = ""
+
 var cfgOnce sync.Once // Singleton config instance.
 var Cfg *Config
 
@@ -19,19 +22,45 @@ type Config struct {
 	Dir               string // Snippets dir.
 	FileViewerCMD     []string
 	MarkdownViewerCMD []string
-	Editor            string
+	EditorCMD         []string // The editor command, it may have {file}, {line} and {col} placeholders.
 	Git               string
 	Exclude           []string // exclude dirs/files. e.g., .git, .idea,...
 	Verbose           bool
 	LogTmpFileName    string
+	IndexFile         string   // The snippets index file path. empty value disables the index (see optionalPath).
+	ClipboardCMD      []string // The command which reads text from stdin and puts it on the clipboard. empty value means OSC 52.
+	TrashDir          string   // Removed snippets are moved to this dir. empty value disables the trash (see optionalPath).
+	ConfigFile        string   // The config file path, it may not exist.
+	Profile           string   // The profile name, it's the config file section too.
+	SyncRemote        string   // The git remote which the sync command pulls from and pushes to.
+	SyncBranch        string   // The remote branch of the sync command. empty value means the upstream branch.
+	SyncStrategy      string   // The pull strategy of the sync command: merge, rebase or ff-only.
+	SyncMessage       string   // The Go template of the sync commit message.
+
+	file     configFile      // The config file content.
+	settings []configSetting // Effective settings and their sources.
+}
+
+// configSetting is an effective setting of the config and its source.
+type configSetting struct {
+	Key    string // Name of the env variable without its prefix in lowercase, e.g., "dir".
+	Value  string
+	Source string // e.g., "env SNIP_DIR", "config file [snip]", "config file" or "default".
 }
 
 func loadConfig(globalPrefix string, appPrefix string) (err error) {
+	var file configFile
+	envOnly := func(name string) string {
+		return DefaultStr(os.Getenv(strings.ToUpper(appPrefix+"_"+name)), os.Getenv(strings.ToUpper(globalPrefix+"_"+name)))
+	}
+	var settings []configSetting
 	env := func(name string, def ...string) string {
-		return DefaultStr(
-			os.Getenv(strings.ToUpper(appPrefix+"_"+name)),
-			append([]string{os.Getenv(strings.ToUpper(globalPrefix + "_" + name))}, def...)...,
-		)
+		setting := configSetting{Key: name, Value: DefaultStr("", def...), Source: "default"}
+		if v, source, ok := lookupSetting(file, globalPrefix, appPrefix, name); ok {
+			setting.Value, setting.Source = v, source
+		}
+		settings = append(settings, setting)
+		return setting.Value
 	}
 	cfgOnce.Do(func() {
 		var homeDir string
@@ -39,14 +68,34 @@ func loadConfig(globalPrefix string, appPrefix string) (err error) {
 		if err != nil {
 			return
 		}
+
+		fpath, explicit := configFilePath(homeDir, envOnly("config"))
+		file, err = readConfigFile(fpath, homeDir, explicit)
+		if err != nil {
+			return
+		}
+
 		Cfg = &Config{
-			Dir:            strings.TrimSuffix(env("dir", path.Join(homeDir, "snippets")), string(os.PathSeparator)),
-			Editor:         env("editor", os.Getenv("EDITOR"), "vim"),
+			Dir:            strings.TrimSuffix(env("dir", defaultDir(homeDir)), string(os.PathSeparator)),
 			Git:            env("git", "git"),
-			Verbose:        env("verbose", "") != "",
+			Verbose:        isTrue(env("verbose")),
 			LogTmpFileName: env("log_tmp_filename"),
+			SyncRemote:     env("sync_remote", "origin"),
+			SyncBranch:     env("sync_branch"),
+			SyncStrategy:   env("sync_strategy", SyncMerge),
+			SyncMessage:    env("sync_message", defaultSyncMessage),
 		}
 
+		// Keep the index file of each app separately.
+		if cacheDir, cacheErr := os.UserCacheDir(); cacheErr == nil {
+			Cfg.IndexFile = optionalPath(env("index_file", filepath.Join(cacheDir, "snip", strings.ToLower(appPrefix)+".index.json")))
+		} else {
+			Cfg.IndexFile = optionalPath(env("index_file"))
+		}
+
+		// Keep the trash of each app separately.
+		Cfg.TrashDir = optionalPath(env("trash_dir", filepath.Join(userDataDir(homeDir), "snip", "trash", strings.ToLower(appPrefix))))
+
 		if exclude := env("exclude", ".git,.idea"); exclude != "" {
 			Cfg.Exclude = strings.Split(exclude, ",")
 		}
@@ -59,8 +108,22 @@ func loadConfig(globalPrefix string, appPrefix string) (err error) {
 		if err != nil {
 			return
 		}
+		Cfg.EditorCMD, err = parseCommand(env("editor", os.Getenv("EDITOR"), "vim"))
+		if err != nil {
+			return
+		}
+		Cfg.ClipboardCMD, err = parseCommand(env("clipboard_cmd"))
+		if err != nil {
+			return
+		}
+
+		Cfg.ConfigFile, Cfg.Profile, Cfg.file, Cfg.settings = fpath, strings.ToLower(appPrefix), file, settings
 
 		// Validation
+		if len(Cfg.EditorCMD) == 0 {
+			err = fmt.Errorf("invalid editor command: %s", Cfg.EditorCMD)
+			return
+		}
 		if len(Cfg.FileViewerCMD) == 0 || len(Cfg.MarkdownViewerCMD) == 0 {
 			err = fmt.Errorf(
 				`invalid viewer commands. file viwer cmd: %s, markdown view cmd: %s`,
@@ -71,10 +134,71 @@ func loadConfig(globalPrefix string, appPrefix string) (err error) {
 		}
 	})
 
+	if err != nil { // The config may not be loaded, so we can't log it.
+		return err
+	}
+
 	Verbose("Prefix: ", prefix, " app_prefix: ", appPrefix, " Config: ", fmt.Sprintf("%#v", Cfg))
 	return
 }
 
+// configFilePath returns the config file path. It's the --config flag or the envPath if they're provided
+// explicitly, otherwise $XDG_CONFIG_HOME/snip/config.toml.
+func configFilePath(homeDir string, envPath string) (string, bool) {
+	if fpath := DefaultStr(FlagConfigFile, envPath); fpath != "" {
+		return fpath, true
+	}
+
+	configDir := DefaultStr(os.Getenv("XDG_CONFIG_HOME"), filepath.Join(homeDir, ".config"))
+	return filepath.Join(configDir, "snip", "config.toml"), false
+}
+
+// defaultDir returns the default snippets dir.
+func defaultDir(homeDir string) string {
+	return path.Join(homeDir, "snippets")
+}
+
+// isTrue reports whether the value of a boolean setting is true. Any non-empty value except false values like
+// "false" and "0" is true.
+func isTrue(v string) bool {
+	b, err := strconv.ParseBool(v)
+	return v != "" && (err != nil || b)
+}
+
+// lookupSetting returns the value of the setting and its source. The profile's settings override the global ones,
+// so it reads them in this order: the {PROFILE}_X env variable, the profile's section of the config file, the
+// SNIP_X env variable and then the global section of the config file.
+func lookupSetting(file configFile, globalPrefix string, appPrefix string, name string) (string, string, bool) {
+	app := strings.ToLower(appPrefix)
+	appKey, globalKey := strings.ToUpper(appPrefix+"_"+name), strings.ToUpper(globalPrefix+"_"+name)
+	if v := os.Getenv(appKey); v != "" {
+		return v, "env " + appKey, true
+	}
+	if v, ok := file.lookupSection(app, name); ok {
+		return v, "config file [" + app + "]", true
+	}
+	if v := os.Getenv(globalKey); v != "" {
+		return v, "env " + globalKey, true
+	}
+
+	v, ok := configFileValue(file[name])
+	return v, "config file", ok
+}
+
+// optionalPath returns the path of an optional feature like the index. An empty env variable means it's not set,
+// so the false value disables the feature (e.g., SNIP_TRASH_DIR=false), like false in the config file.
+func optionalPath(v string) string {
+	if v == "false" {
+		return ""
+	}
+	return v
+}
+
+// userDataDir returns the user's data directory ($XDG_DATA_HOME or ~/.local/share).
+func userDataDir(homeDir string) string {
+	return DefaultStr(os.Getenv("XDG_DATA_HOME"), filepath.Join(homeDir, ".local", "share"))
+}
+
 func (c *Config) ViewerCmd(fname string) *exec.Cmd {
 	// if it's markdown, use markdown viewer, otherwise use file viewer
 	params := c.FileViewerCMD
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+var FlagConfigGlobal = false
+
+// configFile is a TOML config file. Top-level keys are global settings, and tables are per-app sections which
+// override them, e.g.:
+//
+//	editor = "nvim"
+//
+//	[tasks]
+//	dir = "~/tasks"
+//
+// Keys are names of the env variables without their prefix in lowercase (e.g., "dir" for SNIP_DIR).
+type configFile map[string]any
+
+// readConfigFile reads the config file. If the file doesn't exist and it's not provided explicitly by the
+// user, it returns an empty config.
+func readConfigFile(fpath string, homeDir string, explicit bool) (configFile, error) {
+	res := configFile{}
+	_, err := toml.DecodeFile(fpath, &res)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return configFile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not read the config file: %w", err)
+	}
+
+	res.expandHome(homeDir)
+	return res, nil
+}
+
+// expandHome replaces the "~/" prefix of the string values with the home directory.
+func (f configFile) expandHome(homeDir string) {
+	for k, v := range f {
+		switch v := v.(type) {
+		case string:
+			if strings.HasPrefix(v, "~/") {
+				f[k] = filepath.Join(homeDir, v[2:])
+			}
+		case map[string]any:
+			configFile(v).expandHome(homeDir)
+		}
+	}
+}
+
+// lookupSection returns the value of the key from the app's section. lookupSetting falls back to the global section.
+func (f configFile) lookupSection(app string, key string) (string, bool) {
+	section, ok := f[app].(map[string]any)
+	if !ok {
+		return "", false
+	}
+	return configFileValue(section[key])
+}
+
+// sections returns names of the tables (per-app sections) of the config file in sorted order.
+func (f configFile) sections() []string {
+	var res []string
+	for k, v := range f {
+		if _, ok := v.(map[string]any); ok {
+			res = append(res, k)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+// configFileValue converts a config file value to the string format of env variables. Arrays are joined
+// by commas and false is an empty string.
+func configFileValue(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "", true
+	case int64, float64:
+		return fmt.Sprint(v), true
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ","), true
+	default:
+		return "", false
+	}
+}
+
+var (
+	tomlTableRegex = regexp.MustCompile(`^\s*\[\s*([^\[\]]+?)\s*\]\s*(#.*)?$`)
+	tomlKeyRegex   = regexp.MustCompile(`^\s*"?([A-Za-z0-9_-]+)"?\s*=`)
+)
+
+// Keys of the settings which aren't strings in the config file.
+var (
+	configBoolKeys = []string{"verbose"}
+	configListKeys = []string{"exclude"}
+)
+
+// tomlValue converts the value of a setting from the env variables format to a TOML value of its type, e.g.,
+// "false" to false for boolean settings, and ".git,.idea" to [".git", ".idea"] for list settings.
+func tomlValue(key string, value string) (string, error) {
+	switch {
+	case slices.Contains(configBoolKeys, key):
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", invalidArgument("invalid value of %s: %q, use true or false", key, value)
+		}
+		return strconv.FormatBool(b), nil
+	case slices.Contains(configListKeys, key):
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, tomlString(item))
+			}
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	default:
+		return tomlString(value), nil
+	}
+}
+
+// setConfigFileValue sets the key in the section (empty means the global section) of a TOML text as a value of
+// the key's type. It edits the text line by line to keep comments and formatting of the rest of the file.
+func setConfigFileValue(text string, section string, key string, value string) (string, error) {
+	v, err := tomlValue(key, value)
+	if err != nil {
+		return "", err
+	}
+
+	line := key + " = " + v
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if text == "" {
+		lines = nil
+	}
+
+	current, insertAt, found := "", -1, false
+	if section == "" {
+		insertAt = 0
+	}
+	for i := 0; i < len(lines); i++ {
+		if m := tomlTableRegex.FindStringSubmatch(lines[i]); m != nil {
+			current = strings.Trim(m[1], `"`)
+			if current == section {
+				insertAt = i + 1
+			}
+			continue
+		}
+
+		if current != section {
+			continue
+		}
+
+		if strings.TrimSpace(lines[i]) != "" && !strings.HasPrefix(strings.TrimSpace(lines[i]), "#") {
+			insertAt = i + 1
+		}
+
+		if m := tomlKeyRegex.FindStringSubmatch(lines[i]); m != nil && m[1] == key {
+			// Remove the rest of the multi-line arrays.
+			end := i
+			if _, v, _ := strings.Cut(lines[i], "="); strings.HasPrefix(strings.TrimSpace(v), "[") && !strings.Contains(v, "]") {
+				for end < len(lines)-1 && !strings.Contains(lines[end], "]") {
+					end++
+				}
+			}
+			lines = append(lines[:i], append([]string{line}, lines[end+1:]...)...)
+			found = true
+			break
+		}
+	}
+
+	switch {
+	case found:
+	case insertAt != -1:
+		newLines := []string{line}
+		if insertAt < len(lines) && tomlTableRegex.MatchString(lines[insertAt]) { // Keep tables separated.
+			newLines = append(newLines, "")
+		}
+		lines = append(lines[:insertAt], append(newLines, lines[insertAt:]...)...)
+	default:
+		if len(lines) != 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+section+"]", line)
+	}
+
+	res := strings.Join(lines, "\n") + "\n"
+	if _, err := toml.Decode(res, &configFile{}); err != nil {
+		return "", fmt.Errorf("can not set %s, the config file would become invalid: %w", key, err)
+	}
+	return res, nil
+}
+
+// removeConfigFileSection removes the section (the table header and its keys) from a TOML text. It edits the
+// text line by line to keep comments and formatting of the rest of the file.
+func removeConfigFileSection(text string, section string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		m := tomlTableRegex.FindStringSubmatch(line)
+		switch {
+		case m == nil:
+		case start == -1 && strings.Trim(m[1], `"`) == section:
+			start = i
+		case start != -1:
+			end = i
+		}
+		if end != len(lines) {
+			break
+		}
+	}
+	if start == -1 {
+		return text
+	}
+
+	// Comments right above a table header belong to it.
+	isComment := func(i int) bool { return strings.HasPrefix(strings.TrimSpace(lines[i]), "#") }
+	for start > 0 && isComment(start-1) {
+		start--
+	}
+	for end < len(lines) && end > start && isComment(end-1) {
+		end--
+	}
+
+	// Remove blank lines before the section too.
+	for start > 0 && strings.TrimSpace(lines[start-1]) == "" {
+		start--
+	}
+	if start == 0 { // Don't leave blank lines at the beginning of the file.
+		for end < len(lines) && strings.TrimSpace(lines[end]) == "" {
+			end++
+		}
+	} else if end < len(lines) {
+		lines[start] = ""
+		start++
+	}
+
+	lines = append(lines[:start], lines[end:]...)
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// tomlString returns the TOML basic string of the value.
+func tomlString(v string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range v {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteString(`\` + string(r))
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			b.WriteString(fmt.Sprintf(`\u%04X`, r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// configSettingByKey returns the effective setting of the key.
+func configSettingByKey(key string) (configSetting, error) {
+	keys := make([]string, len(Cfg.settings))
+	for i, s := range Cfg.settings {
+		if s.Key == key {
+			return s, nil
+		}
+		keys[i] = s.Key
+	}
+	return configSetting{}, invalidArgument("unknown config key: %s, valid keys: %s", key, strings.Join(keys, ", "))
+}
+
+// writeConfigValue sets the key in the config file. It creates the config file if it doesn't exist.
+func writeConfigValue(section string, key string, value string) error {
+	b, err := os.ReadFile(Cfg.ConfigFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	text, err := setConfigFileValue(string(b), section, key, value)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(Cfg.ConfigFile), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(Cfg.ConfigFile, []byte(text), 0644)
+}
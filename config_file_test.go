@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	res, err := readConfigFile(path.Join(t.TempDir(), "config.toml"), "/home", false)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(res), 0)
+
+	fpath := path.Join(t.TempDir(), "config.toml")
+	assertEqual(t, os.WriteFile(fpath, []byte("dir = \"~/a\"\nn = 2\n[app]\ndir = \"~/b\"\n"), 0644), nil)
+	res, err = readConfigFile(fpath, "/home", true)
+	assertEqual(t, err, nil)
+
+	v, ok := res.lookupSection("app", "dir")
+	assertEqual(t, v, "/home/b")
+	assertTrue(t, ok)
+	_, ok = res.lookupSection("other", "dir")
+	assertTrue(t, !ok)
+	_, ok = res.lookupSection("app", "n") // It's in the global section.
+	assertTrue(t, !ok)
+	_, ok = res.lookupSection("app", "editor")
+	assertTrue(t, !ok)
+	_, ok = res.lookupSection("dir", "app") // Values are not sections.
+	assertTrue(t, !ok)
+
+	v, source, _ := lookupSetting(res, "SNIP_TEST", "OTHER_TEST", "dir")
+	assertEqual(t, v, "/home/a")
+	assertEqual(t, source, "config file")
+	v, _, _ = lookupSetting(res, "SNIP_TEST", "APP", "n")
+	assertEqual(t, v, "2")
+	_, _, ok = lookupSetting(res, "SNIP_TEST", "APP", "app") // Sections are not values.
+	assertTrue(t, !ok)
+	assertEqualSlice(t, res.sections(), []string{"app"})
+}
+
+func TestConfigFileValue(t *testing.T) {
+	cases := []struct {
+		tag string
+		v   any
+		res string
+		ok  bool
+	}{
+		{tag: "t1", v: "a", res: "a", ok: true},
+		{tag: "t2", v: true, res: "true", ok: true},
+		{tag: "t3", v: false, res: "", ok: true},
+		{tag: "t4", v: int64(3), res: "3", ok: true},
+		{tag: "t5", v: []any{".git", "tmp"}, res: ".git,tmp", ok: true},
+		{tag: "t6", v: map[string]any{}, res: ""},
+		{tag: "t7", v: nil, res: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			res, ok := configFileValue(c.v)
+			assertEqual(t, res, c.res)
+			assertEqual(t, ok, c.ok)
+		})
+	}
+}
+
+func TestSetConfigFileValue(t *testing.T) {
+	text := `# My config
+editor = "vim"
+
+[tasks]
+# tasks dir
+dir = "~/tasks"
+exclude = [
+  ".git",
+]
+
+[other]
+git = "git"
+`
+	cases := []struct {
+		tag     string
+		text    string
+		section string
+		key     string
+		value   string
+		res     string
+	}{
+		{tag: "t1", text: "", key: "dir", value: "/a", res: "dir = \"/a\"\n"},
+		{tag: "t2", text: "", section: "tasks", key: "dir", value: "/a", res: "[tasks]\ndir = \"/a\"\n"},
+		{tag: "t3", text: text, key: "editor", value: "nvim",
+			res: strings.Replace(text, `editor = "vim"`, `editor = "nvim"`, 1)},
+		{tag: "t4", text: text, key: "git", value: "g",
+			res: strings.Replace(text, `editor = "vim"`, "editor = \"vim\"\ngit = \"g\"", 1)},
+		{tag: "t5", text: text, section: "tasks", key: "exclude", value: ".a, .b,",
+			res: strings.Replace(text, "exclude = [\n  \".git\",\n]", `exclude = [".a", ".b"]`, 1)},
+		{tag: "t6", text: text, section: "tasks", key: "editor", value: `a "b" \c`,
+			res: strings.Replace(text, "]\n\n[other]", "]\neditor = \"a \\\"b\\\" \\\\c\"\n\n[other]", 1)},
+		{tag: "t7", text: text, section: "new", key: "dir", value: "/a", res: text + "\n[new]\ndir = \"/a\"\n"},
+		{tag: "t8", text: "", key: "verbose", value: "false", res: "verbose = false\n"},
+		{tag: "t9", text: "", key: "verbose", value: "1", res: "verbose = true\n"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			res, err := setConfigFileValue(c.text, c.section, c.key, c.value)
+			assertEqual(t, err, nil)
+			assertEqual(t, res, c.res)
+		})
+	}
+
+	var argErr *argumentError
+	_, err := setConfigFileValue("", "", "verbose", "abc")
+	assertTrue(t, errors.As(err, &argErr))
+	_, err = setConfigFileValue("invalid = ", "", "dir", "/a")
+	assertTrue(t, err != nil)
+}
+
+func TestRemoveConfigFileSection(t *testing.T) {
+	text := `# My config
+editor = "vim"
+
+[tasks]
+dir = "~/tasks"
+
+# other section
+[other]
+git = "git"
+`
+	assertEqual(t, removeConfigFileSection(text, "tasks"), "# My config\neditor = \"vim\"\n\n# other section\n[other]\ngit = \"git\"\n")
+	assertEqual(t, removeConfigFileSection(text, "other"), "# My config\neditor = \"vim\"\n\n[tasks]\ndir = \"~/tasks\"\n")
+	assertEqual(t, removeConfigFileSection(text, "abc"), text)
+	assertEqual(t, removeConfigFileSection("[a]\ndir = \"/a\"\n\n[b]\n", "a"), "[b]\n")
+	assertEqual(t, removeConfigFileSection("[a]\ndir = \"/a\"\n", "a"), "")
+}
+
+func TestTomlString(t *testing.T) {
+	assertEqual(t, tomlString("a\"b\\c\nd\te\x01"), `"a\"b\\c\nd\te\u0001"`)
+}
+
+func TestWriteConfigValue(t *testing.T) {
+	Cfg = &Config{ConfigFile: path.Join(t.TempDir(), "snip/config.toml")}
+	assertEqual(t, writeConfigValue("", "editor", "nvim"), nil)
+	assertEqual(t, writeConfigValue("tasks", "dir", "/tasks"), nil)
+
+	b, err := os.ReadFile(Cfg.ConfigFile)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(b), "editor = \"nvim\"\n\n[tasks]\ndir = \"/tasks\"\n")
+}
+
+func TestConfigSettingByKey(t *testing.T) {
+	Cfg = &Config{settings: []configSetting{{Key: "dir", Value: "/a", Source: "default"}}}
+	s, err := configSettingByKey("dir")
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Value, "/a")
+
+	_, err = configSettingByKey("abc")
+	assertTrue(t, err != nil)
+}
@@ -26,6 +26,7 @@ func resetConfig() {
 func TestLoadDefaultConfig(t *testing.T) {
 	defer resetConfig()
 	setEnv(t, "EDITOR", "abc")
+	setEnv(t, "XDG_CONFIG_HOME", t.TempDir())
 	// Default Values
 	homeDir, err := os.UserHomeDir()
 	assertEqual(t, err, nil)
@@ -34,11 +35,20 @@ func TestLoadDefaultConfig(t *testing.T) {
 	assertEqual(t, Cfg.Dir, path.Join(homeDir, "snippets"))
 	assertEqualSlice(t, Cfg.FileViewerCMD, []string{"cat"})
 	assertEqualSlice(t, Cfg.MarkdownViewerCMD, []string{"cat"})
-	assertEqual(t, Cfg.Editor, "abc")
+	assertEqualSlice(t, Cfg.EditorCMD, []string{"abc"})
 	assertEqual(t, Cfg.Git, "git")
 	assertEqualSlice(t, Cfg.Exclude, []string{".git", ".idea"})
 	assertEqual(t, Cfg.Verbose, false)
 	assertEqual(t, Cfg.LogTmpFileName, "")
+	assertEqual(t, len(Cfg.ClipboardCMD), 0)
+	assertEqual(t, Cfg.SyncRemote, "origin")
+	assertEqual(t, Cfg.SyncBranch, "")
+	assertEqual(t, Cfg.SyncStrategy, SyncMerge)
+	assertEqual(t, Cfg.SyncMessage, defaultSyncMessage)
+
+	cacheDir, err := os.UserCacheDir()
+	assertEqual(t, err, nil)
+	assertEqual(t, Cfg.IndexFile, path.Join(cacheDir, "snip", "test.index.json"))
 }
 
 func TestLoadConfig(t *testing.T) {
@@ -47,33 +57,168 @@ func TestLoadConfig(t *testing.T) {
 	setEnv(t, "TEST_DIR", "/ab/c")
 	setEnv(t, "TEST_FILE_VIEWER_CMD", "touch a")
 	setEnv(t, "TEST_MARKDOWN_VIEWER_CMD", "touch b")
-	setEnv(t, "TEST_EDITOR", "vi")
+	setEnv(t, "TEST_EDITOR", "code --wait")
 	setEnv(t, "TEST_GIT", "abc")
 	setEnv(t, "TEST_EXCLUDE", ".a,.b")
 	setEnv(t, "TEST_VERBOSE", "TRUE")
 	setEnv(t, "TEST_LOG_TMP_FILENAME", "abc.log")
+	setEnv(t, "TEST_INDEX_FILE", "/a/b.json")
+	setEnv(t, "TEST_CLIPBOARD_CMD", "wl-copy -n")
+	setEnv(t, "TEST_SYNC_REMOTE", "upstream")
+	setEnv(t, "TEST_SYNC_BRANCH", "dev")
+	setEnv(t, "TEST_SYNC_STRATEGY", "rebase")
 
 	assertEqual(t, loadConfig("TEST", "TEST"), nil)
 
 	assertEqual(t, Cfg.Dir, "/ab/c")
 	assertEqualSlice(t, Cfg.FileViewerCMD, []string{"touch", "a"})
 	assertEqualSlice(t, Cfg.MarkdownViewerCMD, []string{"touch", "b"})
-	assertEqual(t, Cfg.Editor, "vi")
+	assertEqualSlice(t, Cfg.EditorCMD, []string{"code", "--wait"})
 	assertEqual(t, Cfg.Git, "abc")
 	assertEqualSlice(t, Cfg.Exclude, []string{".a", ".b"})
 	assertTrue(t, Cfg.Verbose)
 	assertEqual(t, Cfg.LogTmpFileName, "abc.log")
+	assertEqual(t, Cfg.IndexFile, "/a/b.json")
+	assertEqualSlice(t, Cfg.ClipboardCMD, []string{"wl-copy", "-n"})
+	assertEqual(t, Cfg.SyncRemote, "upstream")
+	assertEqual(t, Cfg.SyncBranch, "dev")
+	assertEqual(t, Cfg.SyncStrategy, SyncRebase)
 }
 
 func TestLoadConfigInheritance(t *testing.T) {
 	defer resetConfig()
+	setEnv(t, "XDG_CONFIG_HOME", t.TempDir())
 	setEnv(t, "TEST_DIR", "/ab/c/")
 	setEnv(t, "SNIP_DIR", "/ab/d")
 	setEnv(t, "SNIP_GIT", "abc")
+	setEnv(t, "TEST_TRASH_DIR", "false")
+	setEnv(t, "TEST_VERBOSE", "false")
+	setEnv(t, "SNIP_TRASH_DIR", "/ab/trash")
+	setEnv(t, "TEST_INDEX_FILE", "false")
 	assertEqual(t, loadConfig("TEST", "TEST"), nil)
 
 	assertEqual(t, Cfg.Dir, "/ab/c")
 	assertEqual(t, Cfg.Git, "git")
+	assertEqual(t, Cfg.TrashDir, "") // false disables it.
+	assertTrue(t, !Cfg.Verbose)
+	assertEqual(t, Cfg.IndexFile, "")
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	defer resetConfig()
+	fpath := path.Join(t.TempDir(), "config.toml")
+	contents := `
+dir = "/global"
+editor = "nano"
+git = "git2"
+exclude = [".git", "tmp"]
+verbose = true
+
+[test]
+dir = "~/tasks"
+verbose = false
+`
+	assertEqual(t, os.WriteFile(fpath, []byte(contents), 0644), nil)
+	setEnv(t, "TEST_CONFIG", fpath)
+	setEnv(t, "TEST_EDITOR", "vi")
+	setEnv(t, "EDITOR", "emacs")
+
+	assertEqual(t, loadConfig("TEST", "TEST"), nil)
+
+	homeDir, err := os.UserHomeDir()
+	assertEqual(t, err, nil)
+	assertEqual(t, Cfg.Dir, path.Join(homeDir, "tasks")) // app section > global section
+	assertEqualSlice(t, Cfg.EditorCMD, []string{"vi"})   // env > config file
+	assertEqual(t, Cfg.Git, "git2")                      // global section > defaults
+	assertEqualSlice(t, Cfg.Exclude, []string{".git", "tmp"})
+	assertEqual(t, Cfg.Verbose, false)
+	assertEqualSlice(t, Cfg.FileViewerCMD, []string{"cat"})
+	assertEqual(t, Cfg.ConfigFile, fpath)
+	assertEqual(t, Cfg.Profile, "test")
+
+	// Sources of the settings
+	sources := map[string]string{}
+	for _, s := range Cfg.settings {
+		sources[s.Key] = s.Source
+	}
+	assertEqual(t, sources["dir"], "config file [test]")
+	assertEqual(t, sources["editor"], "env TEST_EDITOR")
+	assertEqual(t, sources["git"], "config file")
+	assertEqual(t, sources["file_viewer_cmd"], "default")
+
+	// The --config flag overrides the env variable.
+	resetConfig()
+	Cfg = nil // It must not be used to log the error.
+	FlagConfigFile = path.Join(t.TempDir(), "not-found.toml")
+	defer func() {
+		FlagConfigFile = "" // Reset it.
+	}()
+	assertTrue(t, loadConfig("TEST", "TEST") != nil)
+
+	resetConfig()
+	assertEqual(t, os.WriteFile(FlagConfigFile, []byte("invalid = "), 0644), nil)
+	assertTrue(t, loadConfig("TEST", "TEST") != nil)
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	defer resetConfig()
+	fpath := path.Join(t.TempDir(), "config.toml")
+	contents := `
+dir = "/global"
+git = "git2"
+editor = "nano"
+
+[work]
+dir = "/work"
+`
+	assertEqual(t, os.WriteFile(fpath, []byte(contents), 0644), nil)
+	setEnv(t, "SNIP_CONFIG", fpath)
+	setEnv(t, "SNIP_DIR", "/snippets")
+	setEnv(t, "SNIP_GIT", "git3")
+	setEnv(t, "WORK_EDITOR", "vi")
+
+	// The profile's section overrides the global env variables.
+	assertEqual(t, loadConfig("SNIP", "WORK"), nil)
+	assertEqual(t, Cfg.Dir, "/work")
+	assertEqual(t, Cfg.Git, "git3")                    // global env > global section
+	assertEqualSlice(t, Cfg.EditorCMD, []string{"vi"}) // profile env > profile section
+	sources := map[string]string{}
+	for _, s := range Cfg.settings {
+		sources[s.Key] = s.Source
+	}
+	assertEqual(t, sources["dir"], "config file [work]")
+	assertEqual(t, sources["git"], "env SNIP_GIT")
+	assertEqual(t, sources["editor"], "env WORK_EDITOR")
+
+	res, err := listProfiles()
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, res, []profile{{Name: "work", Dir: "/work"}})
+
+	resetConfig()
+	assertEqual(t, loadConfig("SNIP", "SNIP"), nil)
+	assertEqual(t, Cfg.Dir, "/snippets")
+	res, _ = listProfiles()
+	assertEqualSlice(t, res, []profile{{Name: "snip", Dir: "/snippets"}, {Name: "work", Dir: "/work"}})
+
+	resetConfig()
+	setEnv(t, "WORK_DIR", "/work2")
+	assertEqual(t, loadConfig("SNIP", "WORK"), nil)
+	assertEqual(t, Cfg.Dir, "/work2")
+}
+
+func TestConfigFilePath(t *testing.T) {
+	setEnv(t, "XDG_CONFIG_HOME", "")
+	fpath, explicit := configFilePath("/home/me", "")
+	assertEqual(t, fpath, "/home/me/.config/snip/config.toml")
+	assertTrue(t, !explicit)
+
+	setEnv(t, "XDG_CONFIG_HOME", "/xdg")
+	fpath, _ = configFilePath("/home/me", "")
+	assertEqual(t, fpath, "/xdg/snip/config.toml")
+
+	fpath, explicit = configFilePath("/home/me", "/a.toml")
+	assertEqual(t, fpath, "/a.toml")
+	assertTrue(t, explicit)
 }
 
 func TestConfig_ViewerCmd(t *testing.T) {
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+var (
+	FlagRecursiveCopy = false
+	FlagForceCopy     = false
+	FlagCopyVars      []string
+)
+
+// copySnippetFiles copies the snippet file or directory. If values is not empty, it fills in placeholders of
+// the copied text files. It returns keys of the copied files.
+func copySnippetFiles(m snippetMove, values map[string]string) ([]string, error) {
+	var res []string
+	walkFn := func(key string, info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+
+		to, _ := m.apply(key)
+		fpath := filepath.Join(Cfg.Dir, filepath.FromSlash(key))
+		if err := copySnippetFile(fpath, filepath.Join(Cfg.Dir, filepath.FromSlash(to)), info.Mode().Perm(), values); err != nil {
+			return err
+		}
+		res = append(res, to)
+		return nil
+	}
+
+	if err := walkSnippets(Cfg.Dir, m.From, Cfg.Exclude, walkFn); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// copySnippetFile copies a file and fills in placeholders of its body, the front-matter is copied as-is. It
+// doesn't touch binary files.
+func copySnippetFile(src string, dst string, perm os.FileMode, values map[string]string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	if len(values) != 0 && bytes.IndexByte(b[:min(len(b), binarySniffLen)], 0) == -1 {
+		body := b
+		if isMarkdown(src) {
+			_, body = splitFrontMatter(b)
+		}
+		head := b[:len(b)-len(body)]
+		b = append(append([]byte{}, head...), renderPlaceholders(string(body), values)...)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, b, perm)
+}
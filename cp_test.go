@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestCopySnippetFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir, Exclude: []string{"svc/.git"}}
+	makeTree(t, tmpDir, "svc/.git/config")
+	assertEqual(t, os.MkdirAll(path.Join(tmpDir, "svc/k8s"), 0755), nil)
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "svc/deploy.md"), []byte("deploy {{service}} to {{env:prod}}"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "svc/k8s/bin"), []byte("{{service}}\x00"), 0755), nil)
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "svc/notes.md"), []byte("---\ntitle: {{service}}\n---\n{{service}}"), 0644), nil)
+
+	m := snippetMove{From: "svc", To: "billing", IsDir: true}
+	files, err := copySnippetFiles(m, map[string]string{"service": "billing"})
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, files, []string{"billing/deploy.md", "billing/k8s/bin", "billing/notes.md"})
+
+	// Placeholders of the front-matter are not filled in.
+	b, _ := os.ReadFile(path.Join(tmpDir, "billing/notes.md"))
+	assertEqual(t, string(b), "---\ntitle: {{service}}\n---\nbilling")
+
+	b, _ = os.ReadFile(path.Join(tmpDir, "billing/deploy.md"))
+	assertEqual(t, string(b), "deploy billing to {{env:prod}}")
+	b, _ = os.ReadFile(path.Join(tmpDir, "billing/k8s/bin"))
+	assertEqual(t, string(b), "{{service}}\x00")
+	info, err := os.Stat(path.Join(tmpDir, "billing/k8s/bin"))
+	assertEqual(t, err, nil)
+	assertEqual(t, info.Mode().Perm(), os.FileMode(0755))
+
+	// The source must be untouched.
+	b, _ = os.ReadFile(path.Join(tmpDir, "svc/deploy.md"))
+	assertEqual(t, string(b), "deploy {{service}} to {{env:prod}}")
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Statuses of the doctor checks.
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// errChecksFailed is returned when some doctor checks fail. The report already shows the failures.
+var errChecksFailed = errors.New("some checks failed")
+
+// check is the result of a doctor check. Fix is an actionable fix for warnings and failures.
+type check struct {
+	Name    string
+	Status  string
+	Message string
+	Fix     string
+}
+
+// doctorChecks checks the environment of the app.
+func doctorChecks(appName string) []check {
+	res := []check{
+		checkSnippetsDir(Cfg.Dir),
+		checkCommand(appName, "File viewer", "file_viewer_cmd", Cfg.FileViewerCMD),
+		checkCommand(appName, "Markdown viewer", "markdown_viewer_cmd", Cfg.MarkdownViewerCMD),
+		checkCommand(appName, "Editor", "editor", Cfg.EditorCMD),
+	}
+
+	git := checkCommand(appName, "Git", "git", []string{Cfg.Git})
+	res = append(res, git)
+	if git.Status == CheckOK && res[0].Status == CheckOK {
+		res = append(res, checkGitRepo(appName)...)
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return append(res, check{Name: "Completion", Status: CheckWarn, Message: err.Error()})
+	}
+	return append(res, checkCompletion(filepath.Base(os.Getenv("SHELL")), homeDir, appName)...)
+}
+
+func checkSnippetsDir(dir string) check {
+	res := check{Name: "Snippets dir", Status: CheckOK, Message: dir}
+	info, err := os.Stat(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		res.Status, res.Message = CheckFail, dir+" does not exist"
+		res.Fix = fmt.Sprintf("mkdir -p %s, or set the dir setting (SNIP_DIR) to your snippets dir", dir)
+	case err != nil:
+		res.Status, res.Message = CheckFail, err.Error()
+	case !info.IsDir():
+		res.Status, res.Message = CheckFail, dir+" is not a directory"
+		res.Fix = "Set the dir setting (SNIP_DIR) to your snippets dir"
+	}
+	return res
+}
+
+// checkCommand checks the command of the setting key is on PATH.
+func checkCommand(appName string, name string, key string, argv []string) check {
+	if len(argv) == 0 || argv[0] == "" {
+		return check{Name: name, Status: CheckFail, Message: "the command is empty",
+			Fix: fmt.Sprintf("%s config set %s {command}", appName, key)}
+	}
+
+	fpath, err := exec.LookPath(argv[0])
+	if err == nil {
+		return check{Name: name, Status: CheckOK, Message: fpath}
+	}
+
+	res := check{Name: name, Status: CheckFail, Message: argv[0] + " is not found in PATH",
+		Fix: fmt.Sprintf("Install %s, or set another command by '%s config set %s {command}'", argv[0], appName, key)}
+
+	// Some distributions (e.g., ubuntu) install bat as batcat.
+	if argv[0] == "bat" {
+		if _, err := exec.LookPath("batcat"); err == nil {
+			alt := append([]string{"batcat"}, argv[1:]...)
+			res.Fix = fmt.Sprintf("%s config set %s '%s'", appName, key, strings.Join(alt, " "))
+		}
+	}
+	return res
+}
+
+// checkGitRepo checks the git repository of the snippets dir, its sync remote and upstream branch which the
+// sync command needs.
+func checkGitRepo(appName string) []check {
+	if _, err := gitOutput("rev-parse", "--show-toplevel"); err != nil {
+		return []check{{Name: "Git repo", Status: CheckWarn, Message: "the snippets dir is not a git repository, sync doesn't work",
+			Fix: fmt.Sprintf("%s init --force --remote {your_repo_remote_path}", appName)}}
+	}
+	res := []check{{Name: "Git repo", Status: CheckOK, Message: Cfg.Dir}}
+
+	name := DefaultStr(Cfg.SyncRemote, "origin")
+	remote, err := gitOutput("remote", "get-url", name)
+	if err != nil {
+		return append(res, check{Name: "Git remote", Status: CheckFail, Message: "the git repository has no " + name + " remote",
+			Fix: fmt.Sprintf("cd $(%s dir) && git remote add %s {your_repo_remote_path}", appName, name)})
+	}
+	res = append(res, check{Name: "Git remote", Status: CheckOK, Message: remote})
+
+	if Cfg.SyncBranch != "" { // The sync command doesn't need an upstream branch.
+		return append(res, check{Name: "Git upstream", Status: CheckOK, Message: name + "/" + Cfg.SyncBranch})
+	}
+	upstream, err := gitOutput("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	if err != nil {
+		branch, _ := gitOutput("branch", "--show-current")
+		return append(res, check{Name: "Git upstream", Status: CheckFail, Message: "the current branch has no upstream branch",
+			Fix: fmt.Sprintf("cd $(%s dir) && git push -u %s %s, or set the sync_branch setting", appName, name, DefaultStr(branch, "main"))})
+	}
+	return append(res, check{Name: "Git upstream", Status: CheckOK, Message: upstream})
+}
+
+// checkCompletion checks the shell config files source the completion script, and fzf shell integration in zsh.
+func checkCompletion(shell string, homeDir string, appName string) []check {
+	configDir := DefaultStr(os.Getenv("XDG_CONFIG_HOME"), filepath.Join(homeDir, ".config"))
+	var rcFiles []string
+	switch shell {
+	case "bash":
+		rcFiles = []string{filepath.Join(homeDir, ".bashrc"), filepath.Join(homeDir, ".bash_profile"), filepath.Join(homeDir, ".profile")}
+	case "zsh":
+		rcFiles = []string{filepath.Join(DefaultStr(os.Getenv("ZDOTDIR"), homeDir), ".zshrc")}
+	case "fish":
+		rcFiles = []string{filepath.Join(configDir, "fish", "config.fish"), filepath.Join(configDir, "fish", "completions", appName+".fish")}
+	default:
+		return []check{{Name: "Completion", Status: CheckWarn, Message: fmt.Sprintf("can not check the completion of the %q shell", shell)}}
+	}
+
+	contents := make([]string, len(rcFiles))
+	for i, fpath := range rcFiles {
+		b, _ := os.ReadFile(fpath) // Missing files are like empty ones.
+		contents[i] = string(b)
+	}
+
+	res := []check{{Name: "Completion", Status: CheckWarn, Message: "the completion script is not sourced in " + rcFiles[0],
+		Fix: fmt.Sprintf("echo 'source <(%s completion %s)' >> %s", appName, shell, rcFiles[0])}}
+	if shell == "fish" {
+		res[0].Fix = fmt.Sprintf("echo '%s completion fish | source' >> %s", appName, rcFiles[0])
+	}
+	for i, text := range contents {
+		if strings.Contains(text, appName+" completion") || (shell == "fish" && i == 1 && text != "") {
+			res[0] = check{Name: "Completion", Status: CheckOK, Message: "sourced in " + rcFiles[i]}
+			break
+		}
+	}
+
+	if shell != "zsh" { // Fuzzy completion is supported just in zsh.
+		return res
+	}
+	if _, err := exec.LookPath("fzf"); err != nil {
+		return append(res, check{Name: "Fuzzy completion", Status: CheckWarn, Message: "fzf is not found in PATH",
+			Fix: "Install fzf: https://github.com/junegunn/fzf"})
+	}
+	if !strings.Contains(contents[0], "fzf") {
+		return append(res, check{Name: "Fuzzy completion", Status: CheckWarn, Message: "the fzf shell integration is not loaded in " + rcFiles[0],
+			Fix: fmt.Sprintf("echo 'source <(fzf --zsh)' >> %s", rcFiles[0])})
+	}
+	return append(res, check{Name: "Fuzzy completion", Status: CheckOK, Message: "fzf is loaded in " + rcFiles[0]})
+}
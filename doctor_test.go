@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCheckSnippetsDir(t *testing.T) {
+	dir := t.TempDir()
+	assertEqual(t, checkSnippetsDir(dir).Status, CheckOK)
+
+	res := checkSnippetsDir(path.Join(dir, "abc"))
+	assertEqual(t, res.Status, CheckFail)
+	assertTrue(t, strings.HasPrefix(res.Fix, "mkdir -p "))
+
+	makeTree(t, dir, "a.md")
+	assertEqual(t, checkSnippetsDir(path.Join(dir, "a.md")).Status, CheckFail)
+}
+
+func TestCheckCommand(t *testing.T) {
+	res := checkCommand("snip", "Editor", "editor", []string{"cat"})
+	assertEqual(t, res.Status, CheckOK)
+
+	res = checkCommand("snip", "Editor", "editor", []string{"not-found-cmd"})
+	assertEqual(t, res.Status, CheckFail)
+	assertEqual(t, res.Message, "not-found-cmd is not found in PATH")
+	assertEqual(t, res.Fix, "Install not-found-cmd, or set another command by 'snip config set editor {command}'")
+
+	assertEqual(t, checkCommand("snip", "Editor", "editor", []string{""}).Status, CheckFail)
+
+	// bat is installed as batcat on some distributions.
+	binDir := t.TempDir()
+	makeTree(t, binDir, "batcat")
+	assertEqual(t, os.Chmod(path.Join(binDir, "batcat"), 0755), nil)
+	setEnv(t, "PATH", binDir)
+	res = checkCommand("snip", "File viewer", "file_viewer_cmd", []string{"bat", "--paging", "never"})
+	assertEqual(t, res.Status, CheckFail)
+	assertEqual(t, res.Fix, "snip config set file_viewer_cmd 'batcat --paging never'")
+}
+
+func TestCheckGitRepo(t *testing.T) {
+	dir := t.TempDir()
+	Cfg = &Config{Dir: dir, Git: "git"}
+
+	res := checkGitRepo("snip")
+	assertEqual(t, len(res), 1)
+	assertEqual(t, res[0].Status, CheckWarn)
+
+	initGitRepo(t, dir)
+	res = checkGitRepo("snip")
+	assertEqual(t, len(res), 2)
+	assertEqual(t, res[1].Status, CheckFail)
+
+	remote := t.TempDir()
+	runGit(t, remote, "init", "-q", "--bare")
+	runGit(t, dir, "remote", "add", "origin", remote)
+	runGit(t, dir, "commit", "-q", "--allow-empty", "-m", "init")
+	runGit(t, dir, "branch", "-M", "main")
+	res = checkGitRepo("snip")
+	assertEqual(t, len(res), 3)
+	assertEqual(t, res[1].Status, CheckOK)
+	assertEqual(t, res[2].Status, CheckFail)
+	assertEqual(t, res[2].Fix, "cd $(snip dir) && git push -u origin main, or set the sync_branch setting")
+
+	runGit(t, dir, "push", "-q", "-u", "origin", "main")
+	res = checkGitRepo("snip")
+	assertEqual(t, res[2].Status, CheckOK)
+	assertEqual(t, res[2].Message, "origin/main")
+}
+
+func TestCheckCompletion(t *testing.T) {
+	home := t.TempDir()
+	setEnv(t, "ZDOTDIR", "")
+	setEnv(t, "XDG_CONFIG_HOME", "")
+	setEnv(t, "PATH", t.TempDir()) // fzf is not found.
+
+	res := checkCompletion("bash", home, "snip")
+	assertEqual(t, len(res), 1)
+	assertEqual(t, res[0].Status, CheckWarn)
+	assertEqual(t, res[0].Fix, "echo 'source <(snip completion bash)' >> "+path.Join(home, ".bashrc"))
+
+	assertEqual(t, os.WriteFile(path.Join(home, ".bash_profile"), []byte("source <(snip completion bash)\n"), 0644), nil)
+	res = checkCompletion("bash", home, "snip")
+	assertEqual(t, res[0].Status, CheckOK)
+	assertEqual(t, res[0].Message, "sourced in "+path.Join(home, ".bash_profile"))
+
+	assertEqual(t, os.WriteFile(path.Join(home, ".zshrc"), []byte("source <(snip completion zsh)\n"), 0644), nil)
+	res = checkCompletion("zsh", home, "snip")
+	assertEqual(t, len(res), 2)
+	assertEqual(t, res[0].Status, CheckOK)
+	assertEqual(t, res[1].Status, CheckWarn)
+
+	makeTree(t, home, ".config/fish/completions/snip.fish")
+	assertEqual(t, checkCompletion("fish", home, "snip")[0].Status, CheckOK)
+
+	assertEqual(t, checkCompletion("tcsh", home, "snip")[0].Status, CheckWarn)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	FlagEditLine = 0
+	FlagEditCol  = 0
+)
+
+// editorPresets are arguments of the known editors to open a file at a location. They're used when the editor
+// command doesn't have the {file} placeholder.
+var editorPresets = map[string][]string{
+	"vi":          {"+call cursor({line},{col})", "{file}"},
+	"vim":         {"+call cursor({line},{col})", "{file}"},
+	"nvim":        {"+call cursor({line},{col})", "{file}"},
+	"emacs":       {"+{line}:{col}", "{file}"},
+	"emacsclient": {"+{line}:{col}", "{file}"},
+	"code":        {"--goto", "{file}:{line}:{col}"},
+	"codium":      {"--goto", "{file}:{line}:{col}"},
+	"hx":          {"{file}:{line}:{col}"},
+	"nano":        {"+{line},{col}", "{file}"},
+}
+
+// locationRegex matches the ":line" or ":line:col" suffix of a snippet name, e.g., "docker/build:42".
+var locationRegex = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?$`)
+
+// splitLocation splits the snippet name and the line and column of its ":line:col" suffix. Zero means
+// the location is not provided.
+func splitLocation(name string) (string, int, int) {
+	m := locationRegex.FindStringSubmatch(name)
+	if m == nil {
+		return name, 0, 0
+	}
+
+	line, _ := strconv.Atoi(m[2])
+	col, _ := strconv.Atoi(m[3])
+	return m[1], line, col
+}
+
+// editorArgs returns the editor command which opens the file. The {file}, {line} and {col} placeholders of the
+// editor command are replaced, otherwise if the line is provided, the editor's preset is used to open the file
+// at the line and column.
+func editorArgs(editor []string, file string, line int, col int) []string {
+	args := editor
+	if !slices.ContainsFunc(editor, func(arg string) bool { return strings.Contains(arg, "{file}") }) {
+		preset, ok := editorPresets[strings.TrimSuffix(filepath.Base(editor[0]), ".exe")]
+		if !ok || line == 0 {
+			preset = []string{"{file}"}
+		}
+		args = append(slices.Clip(editor), preset...)
+	}
+
+	r := strings.NewReplacer("{file}", file, "{line}", strconv.Itoa(max(line, 1)), "{col}", strconv.Itoa(max(col, 1)))
+	res := make([]string, len(args))
+	for i, arg := range args {
+		res[i] = r.Replace(arg)
+	}
+	return res
+}
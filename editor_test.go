@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSplitLocation(t *testing.T) {
+	cases := []struct {
+		tag  string
+		name string
+		res  string
+		line int
+		col  int
+	}{
+		{tag: "t1", name: "a/b", res: "a/b"},
+		{tag: "t2", name: "a/b:42", res: "a/b", line: 42},
+		{tag: "t3", name: "a/b.md:42:5", res: "a/b.md", line: 42, col: 5},
+		{tag: "t4", name: "a:b", res: "a:b"},
+		{tag: "t5", name: ":42", res: ":42"},
+		{tag: "t6", name: "a:b:42", res: "a:b", line: 42},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			name, line, col := splitLocation(c.name)
+			assertEqual(t, name, c.res)
+			assertEqual(t, line, c.line)
+			assertEqual(t, col, c.col)
+		})
+	}
+}
+
+func TestEditorArgs(t *testing.T) {
+	cases := []struct {
+		tag    string
+		editor []string
+		line   int
+		col    int
+		res    []string
+	}{
+		{tag: "t1", editor: []string{"vim"}, res: []string{"vim", "/a.md"}},
+		{tag: "t2", editor: []string{"vim"}, line: 42, res: []string{"vim", "+call cursor(42,1)", "/a.md"}},
+		{tag: "t3", editor: []string{"/usr/bin/nvim"}, line: 42, col: 5, res: []string{"/usr/bin/nvim", "+call cursor(42,5)", "/a.md"}},
+		{tag: "t4", editor: []string{"code", "--wait"}, line: 42, col: 5, res: []string{"code", "--wait", "--goto", "/a.md:42:5"}},
+		{tag: "t5", editor: []string{"code", "--wait"}, res: []string{"code", "--wait", "/a.md"}},
+		{tag: "t6", editor: []string{"emacs"}, line: 42, res: []string{"emacs", "+42:1", "/a.md"}},
+		{tag: "t7", editor: []string{"hx"}, line: 42, res: []string{"hx", "/a.md:42:1"}},
+		{tag: "t8", editor: []string{"nano"}, line: 42, col: 5, res: []string{"nano", "+42,5", "/a.md"}},
+		{tag: "t9", editor: []string{"unknown"}, line: 42, res: []string{"unknown", "/a.md"}},
+		{tag: "t10", editor: []string{"subl", "{file}:{line}:{col}"}, line: 42, res: []string{"subl", "/a.md:42:1"}},
+		{tag: "t11", editor: []string{"subl", "{file}:{line}"}, res: []string{"subl", "/a.md:1"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			assertEqualSlice(t, editorArgs(c.editor, "/a.md", c.line, c.col), c.res)
+		})
+	}
+}
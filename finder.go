@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -18,35 +20,134 @@ func baseName(name string) string {
 	return base
 }
 
-func findFiles(root string, search string, exclude []string, searchResultPrepend string) ([]string, error) {
-	Verbose("find files ",
-		"root: ", root,
-		"search: ", search,
-		"exclude: ", exclude,
-		"search_result_prepend: ", searchResultPrepend,
-	)
+// isExcluded reports whether the slash-separated key, which is relative to the snippets dir, is excluded.
+func isExcluded(exclude []string, key string) bool {
+	return slices.ContainsFunc(exclude, func(p string) bool {
+		return path.Clean(filepath.ToSlash(p)) == key
+	})
+}
 
-	root = strings.TrimSuffix(root, string(os.PathSeparator)) + string(os.PathSeparator)
-	var result []string
-	walkFn := func(path string, info os.FileInfo, err error) error {
-		path = strings.TrimPrefix(path, root)
+// snippetsDirKey returns the slash-separated and cleaned key of a path relative to the snippets dir.
+// The key of the snippets dir itself is empty.
+func snippetsDirKey(rel string) string {
+	key := strings.Trim(path.Clean(filepath.ToSlash(rel)), "/")
+	if key == "." {
+		return ""
+	}
+	return key
+}
+
+// symlinkDirInfo is the info of a symlink to a directory, walkers list it but don't look into it.
+type symlinkDirInfo struct {
+	os.FileInfo
+}
+
+// isSymlinkDir reports whether the info is of a symlink to a directory.
+func isSymlinkDir(info os.FileInfo) bool {
+	_, ok := info.(symlinkDirInfo)
+	return ok
+}
+
+// readSnippetsDir reads the directory of the snippets dir and returns its entries which are not excluded.
+// Symlinks are read as their targets, broken symlinks and special files are skipped. Symlinks to directories
+// are returned as symlinkDirInfo, so walking the snippets dir doesn't look into them and never loops.
+func readSnippetsDir(dir string, key string, exclude []string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, err
+	}
+
+	var res []os.FileInfo
+	for _, entry := range entries {
+		fpath := filepath.Join(dir, filepath.FromSlash(key), entry.Name())
+		if isExcluded(exclude, path.Join(key, entry.Name())) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			info, err = os.Stat(fpath)
+			if err == nil && info.IsDir() {
+				info = symlinkDirInfo{info}
+			}
+		}
+		if errors.Is(err, os.ErrNotExist) { // Removed or a broken symlink
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if info.IsDir() || info.Mode().IsRegular() {
+			res = append(res, info)
+		}
+	}
+	return res, nil
+}
+
+// walkSnippets walks the subDir of the snippets dir, and calls fn with the key and info of each file and
+// directory in it, or just with the subDir if it's a file. Keys are slash-separated and relative to the
+// snippets dir, and so are exclude paths. The snippets dir and subDir can be symlinks. fn can return
+// filepath.SkipDir to skip a directory.
+func walkSnippets(dir string, subDir string, exclude []string, fn func(key string, info os.FileInfo) error) error {
+	key := snippetsDirKey(subDir)
+	if key != "" && isExcluded(exclude, key) {
+		return nil
+	}
+
+	info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(key)))
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fn(key, info)
+	}
+	return walkSnippetsDir(dir, key, exclude, fn)
+}
+
+func walkSnippetsDir(dir string, key string, exclude []string, fn func(key string, info os.FileInfo) error) error {
+	infos, err := readSnippetsDir(dir, key, exclude)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		child := path.Join(key, info.Name())
+		err := fn(child, info)
+		if errors.Is(err, filepath.SkipDir) {
+			continue
+		}
 		if err != nil {
 			return err
 		}
 
-		if slices.Contains(exclude, path) {
-			if info.IsDir() {
-				return filepath.SkipDir
+		if info.IsDir() && !isSymlinkDir(info) {
+			if err := walkSnippetsDir(dir, child, exclude, fn); err != nil {
+				return err
 			}
-			return nil
 		}
+	}
+	return nil
+}
 
-		if path == "" { // the root itself should be ignored
-			return nil
-		}
+// findFiles returns the files and directories of the subDir of the snippets dir whose path relative to the
+// subDir contains the search. It doesn't look into matched directories. Results are relative to the subDir
+// and joined to the prepend value.
+func findFiles(dir string, subDir string, search string, exclude []string, searchResultPrepend string) ([]string, error) {
+	Verbose("find files ",
+		"dir: ", dir,
+		"sub_dir: ", subDir,
+		"search: ", search,
+		"exclude: ", exclude,
+		"search_result_prepend: ", searchResultPrepend,
+	)
 
-		if strings.Contains(strings.ToLower(path), strings.ToLower(search)) || search == "" {
-			res := filepath.Join(searchResultPrepend, strings.TrimSuffix(path, ".md")) // Remove .md from end of markdown files.
+	root := snippetsDirKey(subDir)
+	var result []string
+	walkFn := func(key string, info os.FileInfo) error {
+		rel := strings.TrimPrefix(strings.TrimPrefix(key, root), "/")
+		if strings.Contains(strings.ToLower(rel), strings.ToLower(search)) || search == "" {
+			res := filepath.Join(searchResultPrepend, filepath.FromSlash(strings.TrimSuffix(rel, ".md"))) // Remove .md from end of markdown files.
 			if info.IsDir() {
 				res = res + "/" // change style of directories (colorize and append / to them)
 			}
@@ -60,10 +161,17 @@ func findFiles(root string, search string, exclude []string, searchResultPrepend
 		return nil
 	}
 
-	err := filepath.Walk(root, walkFn)
+	info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(root)))
 	if err != nil {
 		return nil, err
 	}
+	if !info.IsDir() { // Like the index, a file has no children.
+		return nil, nil
+	}
+
+	if err := walkSnippets(dir, root, exclude, walkFn); err != nil {
+		return nil, err
+	}
 
 	return result, nil
 }
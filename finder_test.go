@@ -54,12 +54,12 @@ func TestFindFiles(t *testing.T) {
 	makeTree(t, searchDir, paths...)
 
 	cases := []struct {
-		tag       string
-		searchDir string
-		search    string
-		exclude   []string
-		prepend   string
-		res       []string
+		tag     string
+		subDir  string
+		search  string
+		exclude []string
+		prepend string
+		res     []string
 	}{
 		{
 			tag: "t1",
@@ -103,35 +103,77 @@ func TestFindFiles(t *testing.T) {
 			},
 		},
 		{
-			tag:       "t6",
-			searchDir: path.Join(searchDir, "abc"),
+			tag:    "t6",
+			subDir: "abc",
 			res: []string{
 				"def/",
 			},
 		},
 		{
-			tag:       "t7",
-			searchDir: path.Join(searchDir, "abc/def"),
-			search:    "ca",
-			res:       []string{"cart.yaml"},
+			tag:    "t7",
+			subDir: "abc/def",
+			search: "ca",
+			res:    []string{"cart.yaml"},
 		},
 		{
-			tag:       "t8",
-			searchDir: path.Join(searchDir, "abc/def"),
-			search:    "check",
+			tag:    "t8",
+			subDir: "abc/def",
+			search: "check",
 		},
 	}
 
 	// Search
 	for _, c := range cases {
 		t.Run(c.tag, func(t *testing.T) {
-			root := c.searchDir
-			if root == "" {
-				root = searchDir
-			}
-			res, err := findFiles(root, c.search, c.exclude, c.prepend)
+			res, err := findFiles(searchDir, c.subDir, c.search, c.exclude, c.prepend)
 			assertEqual(t, err, nil)
 			assertEqualSlice(t, res, c.res)
 		})
 	}
 }
+
+func TestWalkSnippets(t *testing.T) {
+	defer resetConfig()
+	Cfg = &Config{Verbose: false}
+	target := t.TempDir()
+	makeTree(t, target, "a.md", "b/c.md", "b/x/y.md", ".git/f")
+	assertEqual(t, os.Symlink(path.Join(target, "a.md"), path.Join(target, "link.md")), nil)
+	assertEqual(t, os.Symlink(path.Join(target, "b"), path.Join(target, "b/loop")), nil)
+	assertEqual(t, os.Symlink(path.Join(target, "missing"), path.Join(target, "broken.md")), nil)
+	dir := path.Join(t.TempDir(), "snippets")
+	assertEqual(t, os.Symlink(target, dir), nil) // The snippets dir is a symlink.
+	exclude := []string{".git", "b/x/"}
+
+	var keys []string
+	err := walkSnippets(dir, "", exclude, func(key string, info os.FileInfo) error {
+		keys = append(keys, key)
+		return nil
+	})
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, keys, []string{"a.md", "b", "b/c.md", "b/loop", "link.md"}) // It doesn't look into b/loop.
+
+	keys, err = listSnippets(dir, "b", exclude)
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, keys, []string{"b/c.md"})
+	_, err = listSnippets(dir, "x", exclude)
+	assertTrue(t, err != nil)
+
+	// The index reads the same entries.
+	want, err := findFiles(dir, "", "", exclude, "")
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, want, []string{"a", "b/", "link"})
+	res, err := findFiles(dir, "b", "", exclude, "")
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, res, []string{"c", "loop/"})
+	idx, err := openIndex(path.Join(t.TempDir(), "index.json"), dir, exclude)
+	assertEqual(t, err, nil)
+	assertEqual(t, idx.Update(false), nil)
+	res, ok := idx.Find(".", "", "")
+	assertTrue(t, ok)
+	assertEqualSlice(t, res, want)
+	res, ok = idx.Find("b", "", "")
+	assertTrue(t, ok)
+	assertEqualSlice(t, res, []string{"c", "loop/"})
+	_, ok = idx.Find("b/loop", "", "") // The walker reads it.
+	assertTrue(t, !ok)
+}
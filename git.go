@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitCommand returns a git command which runs in the snippets dir. In structured output formats its output goes
+// to stderr to keep stdout parsable.
+func gitCommand(args ...string) *exec.Cmd {
+	cmd := Command(Cfg.Git, append([]string{"-C", Cfg.Dir}, args...)...)
+	cmd.Stdout = progressWriter()
+	return cmd
+}
+
+// gitOutput runs a git command in the snippets dir and returns its output without the trailing newlines.
+func gitOutput(args ...string) (string, error) {
+	out, err := exec.Command(Cfg.Git, append([]string{"-C", Cfg.Dir}, args...)...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) != 0 {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// gitPath returns the absolute path of the file in the git dir, e.g., "MERGE_HEAD".
+func gitPath(name string) (string, error) {
+	gitDir, err := gitOutput("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(gitDir, name), nil
+}
+
+// gitFileStatuses returns short git statuses (e.g., "M", "??") of the changed files of the snippets dir
+// keyed by their slash-separated paths relative to the snippets dir.
+func gitFileStatuses() (map[string]string, error) {
+	prefix, err := gitOutput("rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := gitOutput("status", "--porcelain", "-z", "--untracked-files=all", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]string{}
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		if len(fields[i]) < 4 {
+			continue
+		}
+
+		status, fpath := fields[i][:2], fields[i][3:]
+		if status[0] == 'R' || status[0] == 'C' {
+			i++ // Skip the source path of renamed or copied files.
+		}
+		rel := strings.TrimPrefix(fpath, prefix)
+		res[path.Clean(rel)] = strings.TrimSpace(status)
+	}
+	return res, nil
+}
+
+// gitTracked reports whether the path (relative to the snippets dir) is tracked by git. It returns false if
+// the snippets dir is not a git repository.
+func gitTracked(fpath string) bool {
+	_, err := gitOutput("ls-files", "--error-unmatch", "--", fpath)
+	return err == nil
+}
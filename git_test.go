@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+// initGitRepo initializes a git repository in the dir with a committer identity.
+func initGitRepo(t *testing.T, dir string) {
+	t.Helper()
+
+	setEnv(t, "GIT_AUTHOR_NAME", "snip")
+	setEnv(t, "GIT_AUTHOR_EMAIL", "snip@example.com")
+	setEnv(t, "GIT_COMMITTER_NAME", "snip")
+	setEnv(t, "GIT_COMMITTER_EMAIL", "snip@example.com")
+	runGit(t, dir, "init", "-q")
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return string(out)
+}
+
+func TestGitOutput(t *testing.T) {
+	dir := t.TempDir()
+	Cfg = &Config{Dir: dir, Git: "git"}
+
+	_, err := gitOutput("rev-parse", "--show-prefix")
+	assertTrue(t, err != nil)
+
+	initGitRepo(t, dir)
+	out, err := gitOutput("rev-parse", "--is-inside-work-tree")
+	assertEqual(t, err, nil)
+	assertEqual(t, out, "true")
+}
+
+func TestGitFileStatuses(t *testing.T) {
+	repo := t.TempDir()
+	Cfg = &Config{Dir: path.Join(repo, "snippets"), Git: "git"}
+	initGitRepo(t, repo)
+	makeTree(t, Cfg.Dir, "a.md", "b.md", "c/d.md")
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "init")
+
+	assertEqual(t, os.WriteFile(path.Join(Cfg.Dir, "a.md"), []byte("changed"), 0644), nil)
+	runGit(t, repo, "mv", "snippets/b.md", "snippets/e.md")
+	makeTree(t, Cfg.Dir, "f.md")
+
+	statuses, err := gitFileStatuses()
+	assertEqual(t, err, nil)
+	assertEqual(t, len(statuses), 3)
+	assertEqual(t, statuses["a.md"], "M")
+	assertEqual(t, statuses["e.md"], "R")
+	assertEqual(t, statuses["f.md"], "??")
+}
+
+func TestGitPath(t *testing.T) {
+	repo := t.TempDir()
+	Cfg = &Config{Dir: path.Join(repo, "snippets"), Git: "git"}
+	initGitRepo(t, repo)
+	makeTree(t, Cfg.Dir, "a.md")
+
+	fpath, err := gitPath("MERGE_HEAD")
+	assertEqual(t, err, nil)
+	assertTrue(t, path.IsAbs(fpath))
+	assertTrue(t, strings.HasSuffix(fpath, "/.git/MERGE_HEAD"))
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const indexVersion = 6 // Bump it whenever the index format changes, old indexes will be rebuilt.
+
+type indexEntry struct {
+	IsDir    bool     `json:"is_dir,omitempty"`
+	Link     bool     `json:"link,omitempty"` // A symlink to a directory, it's not read (see readSnippetsDir).
+	ModTime  int64    `json:"mod_time"`       // Unix nano
+	Size     int64    `json:"size,omitempty"`
+	Children []string `json:"children,omitempty"` // Sorted names of a directory's children.
+
+	contentTerms // Terms of a file's contents.
+}
+
+// Index is an on-disk index of the snippets dir. It keeps the tree of the snippets dir and tokenized contents
+// of the snippets. Entries are keyed by slash-separated paths relative to the snippets dir, the root is "".
+type Index struct {
+	Version int                    `json:"version"`
+	Dir     string                 `json:"dir"`
+	Exclude []string               `json:"exclude"`
+	Entries map[string]*indexEntry `json:"entries"`
+
+	fname string
+	saved []byte // The index file content, so we don't rewrite it if nothing has changed.
+	dirty bool
+}
+
+// openIndex loads the index file. If the index doesn't exist or is built for another snippets dir or
+// another set of exclude paths, it returns an empty index which needs to be updated.
+func openIndex(fname string, dir string, exclude []string) (*Index, error) {
+	idx := &Index{Version: indexVersion, Dir: dir, Exclude: exclude, Entries: map[string]*indexEntry{}}
+
+	b, err := os.ReadFile(fname)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var loaded Index
+	if err == nil && json.Unmarshal(b, &loaded) == nil &&
+		loaded.Version == indexVersion && loaded.Dir == dir && slices.Equal(loaded.Exclude, exclude) &&
+		loaded.Entries != nil {
+		idx.Entries, idx.saved = loaded.Entries, b
+	}
+
+	idx.fname = fname
+	return idx, nil
+}
+
+// Update updates the index incrementally. Directories are re-read only if their mtime has changed. if
+// contents is true, it also tokenizes contents of the files which have changed since the last update.
+func (idx *Index) Update(contents bool) error {
+	return idx.updateDir("", contents)
+}
+
+func (idx *Index) updateDir(rel string, contents bool) error {
+	info, err := os.Stat(filepath.Join(idx.Dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+
+	e := idx.Entries[rel]
+	stale := e == nil || !e.IsDir || e.ModTime != info.ModTime().UnixNano()
+	for i := 0; !stale && i < len(e.Children); i++ {
+		stale = idx.Entries[path.Join(rel, e.Children[i])] == nil // e.g., the index file is edited by hand.
+	}
+	if stale {
+		if e, err = idx.readDir(rel, info); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range e.Children {
+		child := path.Join(rel, name)
+		switch ce := idx.Entries[child]; {
+		case ce.Link: // It may loop, so we don't read it.
+		case ce.IsDir:
+			err = idx.updateDir(child, contents)
+		case contents:
+			err = idx.updateFile(child)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readDir reads children of a directory and replaces the directory's entry.
+func (idx *Index) readDir(rel string, info os.FileInfo) (*indexEntry, error) {
+	infos, err := readSnippetsDir(idx.Dir, rel, idx.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	old := idx.Entries[rel]
+	e := &indexEntry{IsDir: true, ModTime: info.ModTime().UnixNano()}
+	for _, ci := range infos {
+		child := path.Join(rel, ci.Name())
+		e.Children = append(e.Children, ci.Name())
+		if ce := idx.Entries[child]; ce != nil && ce.IsDir == ci.IsDir() && ce.Link == isSymlinkDir(ci) {
+			continue // Keep the entry, it'll be updated if it's changed.
+		}
+
+		idx.removeEntry(child)
+		ce := &indexEntry{IsDir: ci.IsDir(), Link: isSymlinkDir(ci)}
+		if !ci.IsDir() {
+			ce.ModTime, ce.Size = ci.ModTime().UnixNano(), ci.Size()
+		}
+		idx.Entries[child] = ce
+	}
+
+	if old != nil { // Remove deleted children
+		for _, name := range old.Children {
+			if !slices.Contains(e.Children, name) {
+				idx.removeEntry(path.Join(rel, name))
+			}
+		}
+	}
+
+	idx.Entries[rel] = e
+	idx.dirty = true
+	return e, nil
+}
+
+// updateFile tokenizes the file's contents if it's changed since the last update.
+func (idx *Index) updateFile(rel string) error {
+	info, err := os.Stat(filepath.Join(idx.Dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+
+	e := idx.Entries[rel]
+	if e.Terms != nil && e.ModTime == info.ModTime().UnixNano() && e.Size == info.Size() {
+		return nil
+	}
+
+	b, err := os.ReadFile(filepath.Join(idx.Dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+
+	e.ModTime, e.Size = info.ModTime().UnixNano(), info.Size()
+	e.contentTerms = analyzeContents(rel, string(b))
+	idx.dirty = true
+	return nil
+}
+
+func (idx *Index) removeEntry(rel string) {
+	e := idx.Entries[rel]
+	if e == nil {
+		return
+	}
+
+	for _, name := range e.Children {
+		idx.removeEntry(path.Join(rel, name))
+	}
+	delete(idx.Entries, rel)
+	idx.dirty = true
+}
+
+// Save writes the index to its file if it has been changed.
+func (idx *Index) Save() error {
+	if !idx.dirty {
+		return nil
+	}
+
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(b, idx.saved) { // Nothing has changed since we loaded it.
+		idx.dirty = false
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(idx.fname), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temp file and then rename it, so concurrent readers never see a partial index.
+	f, err := os.CreateTemp(filepath.Dir(idx.fname), filepath.Base(idx.fname)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	idx.saved, idx.dirty = b, false
+	return os.Rename(f.Name(), idx.fname)
+}
+
+// Find is like the findFiles function, but uses the index instead of walking the file system.
+// subDir is relative to the snippets dir. It returns false if the subDir doesn't exist in the index.
+func (idx *Index) Find(subDir string, search string, searchResultPrepend string) ([]string, bool) {
+	root := strings.Trim(filepath.ToSlash(filepath.Clean(subDir)), "/")
+	if root == "." {
+		root = ""
+	}
+
+	e := idx.Entries[root]
+	if e == nil || !e.IsDir || e.Link {
+		return nil, false
+	}
+
+	var result []string
+	var walk func(key string, rel string, e *indexEntry)
+	walk = func(key string, rel string, e *indexEntry) { // key is relative to the snippets dir, rel to the subDir.
+		for _, name := range e.Children {
+			childKey, childRel := path.Join(key, name), path.Join(rel, name)
+			child := idx.Entries[childKey]
+			if child == nil {
+				continue
+			}
+
+			if strings.Contains(strings.ToLower(childRel), strings.ToLower(search)) || search == "" {
+				res := filepath.Join(searchResultPrepend, strings.TrimSuffix(childRel, ".md"))
+				if child.IsDir {
+					res = res + "/"
+				}
+				result = append(result, res)
+				continue
+			}
+
+			if child.IsDir {
+				walk(childKey, childRel, child)
+			}
+		}
+	}
+	walk(root, "", e)
+
+	return result, true
+}
+
+// entry returns the entry of a key. It's safe to call it on a nil index.
+func (idx *Index) entry(key string) *indexEntry {
+	if idx == nil {
+		return nil
+	}
+	return idx.Entries[key]
+}
+
+// rankDoc returns the ranking document of an entry.
+func (idx *Index) rankDoc(key string) rankDoc {
+	e := idx.Entries[key]
+	if e == nil {
+		return newRankDoc(key, contentTerms{})
+	}
+	return newRankDoc(key, e.contentTerms)
+}
+
+// loadIndex opens the snippets index and updates it.
+func loadIndex(contents bool) (*Index, error) {
+	idx, err := openIndex(Cfg.IndexFile, Cfg.Dir, Cfg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := idx.Update(contents); err != nil {
+		return nil, err
+	}
+
+	return idx, idx.Save()
+}
+
+// findSnippets finds files using the index if it's enabled, otherwise it walks the snippets dir.
+// Results are sorted by their relevance to the search. searchDir is relative to the snippets dir.
+// It returns the index which it has used, nil if it has walked the snippets dir.
+func findSnippets(searchDir string, search string, exclude []string) ([]string, *Index, error) {
+	if Cfg.IndexFile != "" {
+		idx, err := loadIndex(false)
+		if err == nil {
+			if res, ok := idx.Find(searchDir, search, searchDir); ok {
+				return rankResults(res, search, idx), idx, nil
+			}
+		}
+		Verbose("can not find files in the index, fallback to the file system. err: ", err)
+	}
+
+	res, err := findFiles(Cfg.Dir, searchDir, search, exclude, searchDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rankResults(res, search, nil), nil, nil
+}
+
+// tokenize splits the text into lower-case terms of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestIndex_Find(t *testing.T) {
+	defer resetConfig()
+	Cfg = &Config{Verbose: false}
+	searchDir := t.TempDir()
+	makeTree(t, searchDir,
+		"check.txt",
+		"check.md",
+		"/check/hi.md",
+		"/check/hi.yaml",
+		"/check2/b/cart.yaml",
+		"/abc/def/cart.yaml",
+		".git/a/b.txt",
+	)
+
+	idx, err := openIndex(path.Join(t.TempDir(), "snip.index.json"), searchDir, []string{".git"})
+	assertEqual(t, err, nil)
+	assertEqual(t, idx.Update(false), nil)
+
+	// The index must return the same result as walking the file system.
+	cases := []struct {
+		subDir  string
+		search  string
+		prepend string
+	}{
+		{subDir: "."},
+		{subDir: ".", search: "check"},
+		{subDir: ".", search: "cart"},
+		{subDir: "abc", prepend: "abc"},
+		{subDir: "/abc/def", search: "ca", prepend: "/abc/def"},
+		{subDir: "abc/def", search: "check"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.subDir+c.search, func(t *testing.T) {
+			want, err := findFiles(searchDir, c.subDir, c.search, []string{".git"}, c.prepend)
+			assertEqual(t, err, nil)
+
+			res, ok := idx.Find(c.subDir, c.search, c.prepend)
+			assertTrue(t, ok)
+			assertEqualSlice(t, res, want)
+		})
+	}
+
+	// Excluded and missing dirs are not in the index.
+	_, ok := idx.Find(".git", "", "")
+	assertTrue(t, !ok)
+	_, ok = idx.Find("xyz", "", "")
+	assertTrue(t, !ok)
+}
+
+func TestIndex_Update(t *testing.T) {
+	defer resetConfig()
+	Cfg = &Config{Verbose: false}
+	searchDir := t.TempDir()
+	fname := path.Join(t.TempDir(), "snip.index.json")
+	makeTree(t, searchDir, "a/b.md", "c.md")
+
+	idx, err := openIndex(fname, searchDir, nil)
+	assertEqual(t, err, nil)
+	assertEqual(t, idx.Update(true), nil)
+	assertEqual(t, idx.Entries["a/b.md"].Terms["file"], 1)
+	assertEqual(t, idx.Save(), nil)
+
+	// Reopen the index, and change the snippets.
+	idx, err = openIndex(fname, searchDir, nil)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(idx.Entries), 4)
+
+	later := time.Now().Add(time.Hour)
+	assertEqual(t, os.RemoveAll(path.Join(searchDir, "a")), nil)
+	assertEqual(t, os.WriteFile(path.Join(searchDir, "c.md"), []byte("hello hello"), 0644), nil)
+	assertEqual(t, os.Chtimes(path.Join(searchDir, "c.md"), later, later), nil)
+	makeTree(t, searchDir, "d/e.md")
+
+	assertEqual(t, idx.Update(false), nil)
+	res, _ := idx.Find(".", "", "")
+	assertEqualSlice(t, res, []string{"c", "d/"})
+	assertTrue(t, idx.Entries["a/b.md"] == nil)
+	assertEqual(t, idx.Entries["c.md"].Terms["hello"], 0) // Contents are not updated yet.
+
+	assertEqual(t, idx.Update(true), nil)
+	assertEqual(t, idx.Entries["c.md"].Terms["hello"], 2)
+	assertEqual(t, idx.Entries["c.md"].Length, 2)
+
+	// Index of another dir must be ignored.
+	assertEqual(t, idx.Save(), nil)
+	idx, err = openIndex(fname, t.TempDir(), nil)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(idx.Entries), 0)
+}
+
+func TestIndex_Stale(t *testing.T) {
+	defer resetConfig()
+	Cfg = &Config{Verbose: false}
+	searchDir := t.TempDir()
+	fname := path.Join(t.TempDir(), "snip.index.json")
+	makeTree(t, searchDir, "a/b.md", "c.md")
+
+	idx, err := openIndex(fname, searchDir, nil)
+	assertEqual(t, err, nil)
+	assertEqual(t, idx.Update(false), nil)
+	assertEqual(t, idx.Save(), nil)
+
+	// It doesn't rewrite the index if nothing has changed.
+	old := time.Now().Add(-time.Hour)
+	assertEqual(t, os.Chtimes(fname, old, old), nil)
+	idx, err = openIndex(fname, searchDir, nil)
+	assertEqual(t, err, nil)
+	assertEqual(t, idx.Update(false), nil)
+	idx.dirty = true
+	assertEqual(t, idx.Save(), nil)
+	info, err := os.Stat(fname)
+	assertEqual(t, err, nil)
+	assertTrue(t, info.ModTime().Equal(old))
+
+	// A missing entry (e.g., in a hand-edited index) is re-read instead of failing.
+	delete(idx.Entries, "a")
+	res, ok := idx.Find(".", "", "")
+	assertTrue(t, ok)
+	assertEqualSlice(t, res, []string{"c"})
+	assertEqual(t, idx.Update(false), nil)
+	res, _ = idx.Find(".", "", "")
+	assertEqualSlice(t, res, []string{"a/", "c"})
+}
+
+func TestFindSnippets(t *testing.T) {
+	defer resetConfig()
+	searchDir := t.TempDir()
+	Cfg = &Config{Dir: searchDir, Exclude: []string{".git"}, IndexFile: path.Join(t.TempDir(), "a", "snip.index.json")}
+	makeTree(t, searchDir, "abc/def.md", ".git/a.md")
+
+	res, idx, err := findSnippets(".", "", Cfg.Exclude)
+	assertEqual(t, err, nil)
+	assertTrue(t, idx != nil)
+	assertEqualSlice(t, res, []string{"abc/"})
+	assertExists(t, path.Dir(Cfg.IndexFile), "snip.index.json")
+
+	// Fallback to the file system
+	res, idx, err = findSnippets(".git", "", nil)
+	assertEqual(t, err, nil)
+	assertTrue(t, idx == nil)
+	assertEqualSlice(t, res, []string{".git/a"})
+}
+
+func TestTokenize(t *testing.T) {
+	assertEqualSlice(t, tokenize("Hello, World! jq's group_by 42"), []string{"hello", "world", "jq", "s", "group", "by", "42"})
+}
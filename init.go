@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	FlagInitRemote = ""
+	FlagInitClone  = ""
+	FlagInitForce  = false
+)
+
+// Files which init adds to a new snippets repository. Snippets are markdown files, so the README is a snippet too.
+const (
+	initGitignore = `# Editors and OS files
+.idea/
+.vscode/
+*.swp
+*~
+.DS_Store
+`
+	initReadme = `# Snippets
+
+My snippets, which are managed and synced by [snip](https://github.com/mehran-prs/snip).
+`
+)
+
+// initResult is the result of initializing the snippets repository.
+type initResult struct {
+	Dir       string
+	Remote    string // The remote URL, empty if the repository has no remote.
+	Branch    string
+	Cloned    bool
+	Committed bool // The initial commit is created.
+	Pushed    bool // The initial commit is pushed and the upstream branch is set.
+}
+
+// initSnippetsRepo makes the snippets dir a git repository which the sync command can use. It clones the
+// clone URL into the dir, otherwise it initializes a repository with a .gitignore and README and commits them.
+// If the repository has a remote, it pushes the branch and sets its upstream branch.
+func initSnippetsRepo(remoteURL string, cloneURL string, force bool) (initResult, error) {
+	res := initResult{Dir: Cfg.Dir}
+	if remoteURL != "" && cloneURL != "" {
+		return res, invalidArgument("the --remote and --clone flags can not be used together")
+	}
+
+	entries, err := os.ReadDir(Cfg.Dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return res, err
+	}
+	if len(entries) != 0 && !force {
+		return res, fmt.Errorf("%s is not empty, use --force to init it anyway: %w", Cfg.Dir, os.ErrExist)
+	}
+	_, err = os.Stat(filepath.Join(Cfg.Dir, ".git"))
+	if err == nil && cloneURL != "" {
+		return res, fmt.Errorf("%s is a git repository already, add the remote by 'git remote add' instead: %w", Cfg.Dir, os.ErrExist)
+	}
+	if err := os.MkdirAll(Cfg.Dir, 0777); err != nil {
+		return res, err
+	}
+
+	progress := progressWriter()
+	fmt.Fprintln(progress, "Initialize the git repository")
+	if err := gitCommand("init", "-q").Run(); err != nil {
+		return res, err
+	}
+
+	remote := DefaultStr(Cfg.SyncRemote, "origin")
+	res.Remote = DefaultStr(remoteURL, cloneURL)
+	if res.Remote != "" {
+		if err := gitCommand("remote", "add", remote, res.Remote).Run(); err != nil {
+			return res, err
+		}
+	}
+
+	if cloneURL != "" {
+		if res.Cloned, err = checkoutRemote(remote); err != nil {
+			return res, err
+		}
+	}
+
+	if res.Branch, err = gitOutput("branch", "--show-current"); err != nil {
+		return res, err
+	}
+
+	// A cloned repository has its files, and an existing repository has its own commits.
+	if _, err := gitOutput("rev-parse", "-q", "--verify", "HEAD"); err != nil {
+		for fname, content := range map[string]string{".gitignore": initGitignore, "README.md": initReadme} {
+			if err := writeNewFile(filepath.Join(Cfg.Dir, fname), content); err != nil {
+				return res, err
+			}
+		}
+
+		fmt.Fprintln(progress, "Create the initial commit")
+		if err := gitCommand("add", "-A").Run(); err != nil {
+			return res, err
+		}
+		if err := gitCommand("commit", "-q", "-m", "snip: Initial commit").Run(); err != nil {
+			return res, err
+		}
+		res.Committed = true
+	}
+
+	if _, err := gitOutput("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}"); err == nil || res.Remote == "" {
+		return res, nil
+	}
+	fmt.Fprintln(progress, "Push the branch and set its upstream branch")
+	if err := gitCommand("push", "-u", remote, res.Branch).Run(); err != nil {
+		return res, err
+	}
+	res.Pushed = true
+	return res, nil
+}
+
+// checkoutRemote fetches the remote and checks out its default branch. Local files are kept as local changes,
+// so init --force doesn't lose them. It returns false if the remote repository is empty.
+func checkoutRemote(remote string) (bool, error) {
+	fmt.Fprintln(progressWriter(), "Fetch the remote repository")
+	if err := gitCommand("fetch", "-q", remote).Run(); err != nil {
+		return false, err
+	}
+	if _, err := gitOutput("remote", "set-head", remote, "--auto"); err != nil {
+		if branches, _ := gitOutput("branch", "-r"); branches != "" {
+			return false, fmt.Errorf("can not find the default branch of the %s remote: %w", remote, err)
+		}
+		return false, nil // The remote has no branches.
+	}
+	upstream, err := gitOutput("rev-parse", "--abbrev-ref", remote+"/HEAD")
+	if err != nil {
+		return false, err
+	}
+
+	branch := strings.TrimPrefix(upstream, remote+"/")
+	if _, err := gitOutput("symbolic-ref", "HEAD", "refs/heads/"+branch); err != nil {
+		return false, err
+	}
+	if _, err := gitOutput("reset", "-q", upstream); err != nil {
+		return false, err
+	}
+	if _, err := gitOutput("branch", "-q", "--set-upstream-to", upstream); err != nil {
+		return false, err
+	}
+
+	// Files which aren't in the dir are reported as deleted, restore them.
+	deleted, err := gitOutput("ls-files", "-d", "-z")
+	if err != nil {
+		return false, err
+	}
+	if files := strings.Split(strings.TrimRight(deleted, "\x00"), "\x00"); deleted != "" {
+		if _, err := gitOutput(append([]string{"checkout", "--"}, files...)...); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// writeNewFile writes the file if it doesn't exist.
+func writeNewFile(fpath string, content string) error {
+	f, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
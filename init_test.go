@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestInitSnippetsRepo(t *testing.T) {
+	dir := t.TempDir()
+	initGitRepo(t, dir) // Sets the git identity.
+	runGit(t, dir, "init", "-q", "--bare", "remote")
+	Cfg = &Config{Dir: path.Join(dir, "snippets"), Git: "git"}
+
+	res, err := initSnippetsRepo(path.Join(dir, "remote"), "", false)
+	assertEqual(t, err, nil)
+	assertTrue(t, res.Committed)
+	assertTrue(t, res.Pushed)
+	assertExists(t, Cfg.Dir, ".gitignore", "README.md")
+	assertEqual(t, runGit(t, Cfg.Dir, "status", "--porcelain"), "")
+	upstream := strings.TrimSpace(runGit(t, Cfg.Dir, "rev-parse", "--abbrev-ref", "@{upstream}"))
+	assertEqual(t, upstream, "origin/"+res.Branch)
+
+	// It refuses to init a non-empty dir.
+	_, err = initSnippetsRepo("", "", false)
+	assertTrue(t, errors.Is(err, os.ErrExist))
+
+	// Clone it
+	Cfg.Dir = path.Join(dir, "clone")
+	res, err = initSnippetsRepo("", path.Join(dir, "remote"), false)
+	assertEqual(t, err, nil)
+	assertTrue(t, res.Cloned)
+	assertTrue(t, !res.Committed)
+	assertTrue(t, !res.Pushed)
+	assertExists(t, Cfg.Dir, ".gitignore", "README.md")
+	assertEqual(t, runGit(t, Cfg.Dir, "status", "--porcelain"), "")
+
+	// Clone it into a non-empty dir, local files are kept as local changes.
+	Cfg.Dir = path.Join(dir, "local")
+	makeTree(t, Cfg.Dir, "a.md")
+	assertEqual(t, os.WriteFile(path.Join(Cfg.Dir, "README.md"), []byte("local\n"), 0644), nil)
+	_, err = initSnippetsRepo("", path.Join(dir, "remote"), false)
+	assertTrue(t, errors.Is(err, os.ErrExist))
+	res, err = initSnippetsRepo("", path.Join(dir, "remote"), true)
+	assertEqual(t, err, nil)
+	assertTrue(t, res.Cloned)
+	assertExists(t, Cfg.Dir, ".gitignore")
+	assertEqual(t, runGit(t, Cfg.Dir, "status", "--porcelain"), " M README.md\n?? a.md\n")
+
+	// A git repository can't be cloned into.
+	_, err = initSnippetsRepo("", path.Join(dir, "remote"), true)
+	assertTrue(t, errors.Is(err, os.ErrExist))
+
+	var argErr *argumentError
+	_, err = initSnippetsRepo("a", "b", true)
+	assertTrue(t, errors.As(err, &argErr))
+}
+
+func TestInitSnippetsRepoEmptyClone(t *testing.T) {
+	dir := t.TempDir()
+	initGitRepo(t, dir) // Sets the git identity.
+	runGit(t, dir, "init", "-q", "--bare", "remote")
+	Cfg = &Config{Dir: path.Join(dir, "snippets"), Git: "git", SyncRemote: "upstream"}
+
+	// The remote repository is empty, so we init it.
+	res, err := initSnippetsRepo("", path.Join(dir, "remote"), false)
+	assertEqual(t, err, nil)
+	assertTrue(t, !res.Cloned)
+	assertTrue(t, res.Committed)
+	assertTrue(t, res.Pushed)
+	upstream := strings.TrimSpace(runGit(t, Cfg.Dir, "rev-parse", "--abbrev-ref", "@{upstream}"))
+	assertEqual(t, upstream, "upstream/"+res.Branch)
+}
+
+func TestWriteNewFile(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "a")
+	assertEqual(t, writeNewFile(fpath, "a"), nil)
+	assertEqual(t, writeNewFile(fpath, "b"), nil)
+	b, _ := os.ReadFile(fpath)
+	assertEqual(t, string(b), "a")
+}
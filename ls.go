@@ -0,0 +1,261 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	FlagListTags  []string
+	FlagListTree  = false
+	FlagListLong  = false
+	FlagListSort  = "name"
+	FlagListDepth = 0
+)
+
+var listSortOrders = []string{"name", "mtime", "size"}
+
+// listEntry is a file or directory of the snippets dir.
+type listEntry struct {
+	Key      string // Slash-separated path relative to the snippets dir.
+	IsDir    bool
+	Size     int64
+	ModTime  time.Time
+	Children []*listEntry // Nil if the directory is deeper than the depth limit.
+}
+
+// listSnippets walks the subDir of the snippets dir and returns slash-separated paths of the snippet files
+// relative to the snippets dir. exclude paths are relative to the snippets dir.
+func listSnippets(dir string, subDir string, exclude []string) ([]string, error) {
+	var result []string
+	walkFn := func(key string, info os.FileInfo) error {
+		if !info.IsDir() {
+			result = append(result, key)
+		}
+		return nil
+	}
+
+	if err := walkSnippets(dir, subDir, exclude, walkFn); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readListTree reads the tree of the subDir of the snippets dir.
+func readListTree(dir string, subDir string, exclude []string) (*listEntry, error) {
+	key := snippetsDirKey(subDir)
+	info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", subDir)
+	}
+
+	root := &listEntry{Key: key, IsDir: true, ModTime: info.ModTime(), Children: []*listEntry{}}
+	dirs := map[string]*listEntry{key: root}
+	walkFn := func(key string, info os.FileInfo) error {
+		e := &listEntry{Key: key, IsDir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime()}
+		if e.IsDir {
+			e.Children = []*listEntry{}
+			dirs[key] = e
+		}
+
+		parent := dirs[snippetsDirKey(path.Dir(key))]
+		parent.Children = append(parent.Children, e)
+		return nil
+	}
+	return root, walkSnippets(dir, key, exclude, walkFn)
+}
+
+// filterListTree removes files which don't match the filter, and also directories which become empty.
+// It returns false if the entry itself should be removed.
+func filterListTree(e *listEntry, match func(e *listEntry) bool) bool {
+	if !e.IsDir {
+		return match(e)
+	}
+
+	e.Children = slices.DeleteFunc(e.Children, func(child *listEntry) bool {
+		return !filterListTree(child, match)
+	})
+	return len(e.Children) != 0
+}
+
+// truncateListTree removes children of directories which are deeper than the depth. depth 0 means unlimited.
+func truncateListTree(e *listEntry, depth int) {
+	for _, child := range e.Children {
+		if depth == 1 {
+			child.Children = nil
+		} else {
+			truncateListTree(child, max(depth-1, 0))
+		}
+	}
+}
+
+// compareListEntries compares entries by the sort order. ties are broken by their names.
+func compareListEntries(order string) func(a, b *listEntry) int {
+	return func(a, b *listEntry) int {
+		var res int
+		switch order {
+		case "mtime":
+			res = b.ModTime.Compare(a.ModTime) // Newest first
+		case "size":
+			res = cmp.Compare(b.Size, a.Size) // Largest first
+		}
+		if res == 0 {
+			res = strings.Compare(a.Key, b.Key)
+		}
+		return res
+	}
+}
+
+// sortListTree sorts children of each directory.
+func sortListTree(e *listEntry, order string) {
+	slices.SortStableFunc(e.Children, compareListEntries(order))
+	for _, child := range e.Children {
+		sortListTree(child, order)
+	}
+}
+
+// flattenListTree returns files of the tree and also directories which are deeper than the depth limit.
+func flattenListTree(e *listEntry) []*listEntry {
+	var res []*listEntry
+	for _, child := range e.Children {
+		if child.IsDir && child.Children != nil {
+			res = append(res, flattenListTree(child)...)
+		} else {
+			res = append(res, child)
+		}
+	}
+	return res
+}
+
+// listEntryName returns the display name of the entry, like auto-completion, it removes the markdown
+// extension and appends a slash to directories.
+func listEntryName(e *listEntry, base bool) string {
+	name := e.Key
+	if base {
+		name = path.Base(name)
+	}
+
+	if e.IsDir {
+		return filepath.FromSlash(name) + "/"
+	}
+	return snippetName(name)
+}
+
+// printListTree prints the tree like the tree command.
+func printListTree(w io.Writer, root *listEntry, rootName string) error {
+	if _, err := fmt.Fprintln(w, rootName); err != nil {
+		return err
+	}
+
+	var walk func(e *listEntry, indent string) error
+	walk = func(e *listEntry, indent string) error {
+		for i, child := range e.Children {
+			branch, childIndent := "├── ", "│   "
+			if i == len(e.Children)-1 {
+				branch, childIndent = "└── ", "    "
+			}
+
+			if _, err := fmt.Fprintln(w, indent+branch+listEntryName(child, true)); err != nil {
+				return err
+			}
+			if err := walk(child, indent+childIndent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root, "")
+}
+
+// printListLong prints size, modification time, git status, name and the title of the entries.
+func printListLong(w io.Writer, dir string, entries []*listEntry, gitStatuses map[string]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, e := range entries {
+		size, title := "-", ""
+		if !e.IsDir {
+			size = humanSize(e.Size)
+			title = snippetTitle(filepath.Join(dir, filepath.FromSlash(e.Key)))
+		}
+
+		_, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			size,
+			e.ModTime.Format("2006-01-02 15:04"),
+			DefaultStr(gitStatuses[e.Key], "-"),
+			listEntryName(e, false),
+			title,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// listItemDocs returns structured documents of the entries.
+func listItemDocs(dir string, entries []*listEntry, gitStatuses map[string]string) []ListItemDoc {
+	res := make([]ListItemDoc, 0, len(entries))
+	for _, e := range entries {
+		doc := ListItemDoc{
+			Name:      listEntryName(e, false),
+			Path:      filepath.Join(dir, filepath.FromSlash(e.Key)),
+			IsDir:     e.IsDir,
+			Size:      e.Size,
+			ModTime:   e.ModTime.Format(time.RFC3339),
+			GitStatus: gitStatuses[e.Key],
+		}
+		if !e.IsDir {
+			doc.Title = snippetTitle(doc.Path)
+		}
+		res = append(res, doc)
+	}
+	return res
+}
+
+// snippetTitle returns the front-matter title of a snippet, or its first markdown heading.
+func snippetTitle(fpath string) string {
+	b, err := os.ReadFile(fpath)
+	if err != nil {
+		return ""
+	}
+
+	meta, body, _ := parseSnippet(fpath, b)
+	if meta.Title != "" || !isMarkdown(fpath) {
+		return meta.Title
+	}
+
+	if headings := markdownHeadings(string(body)); len(headings) != 0 {
+		return headings[0]
+	}
+	return ""
+}
+
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// snippetName returns the snippet name which we show to users. like auto-completion, it removes the
+// markdown extension.
+func snippetName(key string) string {
+	return strings.TrimSuffix(filepath.FromSlash(key), ".md")
+}
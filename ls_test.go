@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListSnippets(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, "a.md", "b/c.md", "b/d/e.yaml", ".git/f", "g/")
+
+	keys, err := listSnippets(dir, "", []string{".git"})
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, keys, []string{"a.md", "b/c.md", "b/d/e.yaml"})
+
+	keys, err = listSnippets(dir, "b/d", []string{".git"})
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, keys, []string{"b/d/e.yaml"})
+
+	_, err = listSnippets(dir, "x", nil)
+	assertTrue(t, err != nil)
+}
+
+func listTreeNames(entries []*listEntry) []string {
+	var res []string
+	for _, e := range entries {
+		res = append(res, listEntryName(e, false))
+	}
+	return res
+}
+
+func TestReadListTree(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, "a.md", "b/c.md", "b/d/e.yaml", ".git/f", "g/")
+	assertEqual(t, os.WriteFile(path.Join(dir, "b/c.md"), []byte(strings.Repeat("a", 1000)), 0644), nil)
+	old := time.Now().Add(-time.Hour)
+	assertEqual(t, os.Chtimes(path.Join(dir, "b/d/e.yaml"), old, old), nil)
+
+	tree, err := readListTree(dir, "", []string{".git"})
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, listTreeNames(flattenListTree(tree)), []string{"a", "b/c", "b/d/e.yaml"})
+
+	// Sort
+	entries := flattenListTree(tree)
+	sortEntries := func(order string) []string {
+		res := append([]*listEntry{}, entries...)
+		slices.SortStableFunc(res, compareListEntries(order))
+		return listTreeNames(res)
+	}
+	assertEqualSlice(t, sortEntries("size"), []string{"b/c", "b/d/e.yaml", "a"})
+	assertEqualSlice(t, sortEntries("mtime")[2:], []string{"b/d/e.yaml"})
+
+	// Depth
+	truncateListTree(tree, 1)
+	assertEqualSlice(t, listTreeNames(flattenListTree(tree)), []string{"a", "b/", "g/"})
+
+	// Sub directory
+	tree, err = readListTree(dir, "b/", nil)
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, listTreeNames(flattenListTree(tree)), []string{"b/c", "b/d/e.yaml"})
+
+	_, err = readListTree(dir, "a.md", nil)
+	assertTrue(t, err != nil)
+	_, err = readListTree(dir, "x", nil)
+	assertTrue(t, err != nil)
+}
+
+func TestFilterListTree(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, "a.md", "b/c.md", "b/d/e.yaml", "g/")
+
+	tree, err := readListTree(dir, "", nil)
+	assertEqual(t, err, nil)
+	filterListTree(tree, func(e *listEntry) bool {
+		return strings.HasSuffix(e.Key, ".yaml")
+	})
+	assertEqual(t, len(tree.Children), 1)
+	assertEqualSlice(t, listTreeNames(flattenListTree(tree)), []string{"b/d/e.yaml"})
+}
+
+func TestPrintListTree(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, "a.md", "b/c.md", "b/d/e.yaml", "f.md")
+
+	tree, err := readListTree(dir, "", nil)
+	assertEqual(t, err, nil)
+
+	var buf bytes.Buffer
+	assertEqual(t, printListTree(&buf, tree, "."), nil)
+	assertEqual(t, buf.String(), `.
+├── a
+├── b/
+│   ├── c
+│   └── d/
+│       └── e.yaml
+└── f
+`)
+}
+
+func TestPrintListLong(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, "a.md", "b.yaml")
+	assertEqual(t, os.WriteFile(path.Join(dir, "a.md"), []byte("# Title\ntext"), 0644), nil)
+
+	tree, err := readListTree(dir, "", nil)
+	assertEqual(t, err, nil)
+
+	var buf bytes.Buffer
+	assertEqual(t, printListLong(&buf, dir, flattenListTree(tree), map[string]string{"a.md": "M"}), nil)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assertEqual(t, len(lines), 2)
+	assertTrue(t, strings.HasPrefix(lines[0], "12B "), lines[0])
+	assertTrue(t, strings.HasSuffix(lines[0], "  M  a       Title"), lines[0])
+	assertTrue(t, strings.HasSuffix(lines[1], "  -  b.yaml"), lines[1])
+}
+
+func TestSnippetTitle(t *testing.T) {
+	dir := t.TempDir()
+	assertEqual(t, os.WriteFile(path.Join(dir, "a.md"), []byte("---\ntitle: Meta\n---\n# Title"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, "b.md"), []byte("text\n## Title"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, "c.sh"), []byte("# comment"), 0644), nil)
+
+	assertEqual(t, snippetTitle(path.Join(dir, "a.md")), "Meta")
+	assertEqual(t, snippetTitle(path.Join(dir, "b.md")), "Title")
+	assertEqual(t, snippetTitle(path.Join(dir, "c.sh")), "")
+	assertEqual(t, snippetTitle(path.Join(dir, "d.md")), "")
+}
+
+func TestHumanSize(t *testing.T) {
+	assertEqual(t, humanSize(10), "10B")
+	assertEqual(t, humanSize(1536), "1.5K")
+	assertEqual(t, humanSize(3*1024*1024), "3.0M")
+}
+
+func TestSnippetName(t *testing.T) {
+	assertEqual(t, snippetName("a/b.md"), "a/b")
+	assertEqual(t, snippetName("a/b.yaml"), "a/b.yaml")
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -20,7 +26,11 @@ var (
 	Date    = "" // fill-in at compile time.
 )
 
-var FlagRecursiveRemove = false
+var (
+	FlagRecursiveRemove = false
+	FlagRebuildIndex    = false
+	FlagShowFrontMatter = false
+)
 
 func init() {
 	appName = DefaultStr(baseName(os.Args[0]), "snip")
@@ -29,13 +39,34 @@ func init() {
 
 func main() {
 	if err := run(); err != nil {
-		Error("app error: ", err)
-		os.Exit(1)
+		var runErr *runError
+		switch {
+		case errors.Is(err, errChecksFailed): // The doctor report has already shown the failures.
+		case isStructuredOutput():
+			_ = printError(os.Stdout, err)
+		case !errors.As(err, &runErr): // The snippet script has already printed its errors.
+			Error("app error: ", err)
+		}
+		os.Exit(exitCode(err))
 	}
 }
 
 func run() error {
+	rootCmd := newRootCmd()
+
+	// Invalid flags and args fail before boot, so we silence their errors here to print them just once.
+	if output := outputFlagValue(os.Args[1:]); slices.Contains(outputFormats, output) {
+		FlagOutput = output
+	}
+	if isStructuredOutput() {
+		rootCmd.SilenceErrors, rootCmd.SilenceUsage = true, true
+	}
+
+	return rootCmd.Execute()
+}
 
+// newRootCmd builds the command tree of the app.
+func newRootCmd() *cobra.Command {
 	var completionCmd = &cobra.Command{
 		Use:                   "completion [bash|zsh|fish|powershell]",
 		Short:                 "Generate completion script",
@@ -56,6 +87,73 @@ func run() error {
 		ValidArgsFunction:  cobraAutoCompleteFileName,
 	}
 
+	var addCmd = &cobra.Command{
+		Use:   "add [--append|--force] [--title title] name",
+		Short: "Create a snippet from stdin",
+		Long: `Creates a snippet from stdin, e.g., 'history | tail -1 | snip add shell/last'. It asks for confirmation before
+overwriting an existing snippet, use --force to overwrite it without confirmation or --append to append to it.`,
+		Args:              cobra.ExactArgs(1),
+		RunE:              CmdAdd,
+		ValidArgsFunction: cobraAutoCompleteFileName,
+	}
+
+	var cpCmd = &cobra.Command{
+		Use:   "cp [-r] [--set key=value]... src dst",
+		Short: "Copy a snippet or directory to a new snippet",
+		Long: `Copies a snippet file or a snippet directory (append a '/' to directories, and use -r to copy them) to start a new
+snippet from it. Use --set to fill in placeholders of the copied snippets, e.g., --set service=billing.`,
+		Args:              cobra.ExactArgs(2),
+		RunE:              CmdCp,
+		ValidArgsFunction: cobraAutoCompleteFileName,
+	}
+
+	var configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and edit the settings",
+	}
+
+	var configListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the effective settings and their sources",
+		Args:  cobra.NoArgs,
+		RunE:  CmdConfigList,
+	}
+
+	var configGetCmd = &cobra.Command{
+		Use:               "get key",
+		Short:             "Print the effective value of a setting",
+		Args:              cobra.ExactArgs(1),
+		RunE:              CmdConfigGet,
+		ValidArgsFunction: cobraAutoCompleteConfigKey,
+	}
+
+	var configSetCmd = &cobra.Command{
+		Use:   "set [--global] key value",
+		Short: "Set a setting in the config file",
+		Long: `Sets a setting in the app's section of the config file (e.g., [tasks] for the tasks command). Use --global to set it
+in the global section which applies to all apps.`,
+		Args:              cobra.ExactArgs(2),
+		RunE:              CmdConfigSet,
+		ValidArgsFunction: cobraAutoCompleteConfigKey,
+	}
+
+	var configEditCmd = &cobra.Command{
+		Use:   "edit",
+		Short: "Open the config file in the editor",
+		Args:  cobra.NoArgs,
+		RunE:  CmdConfigEdit,
+	}
+
+	var copyCmd = &cobra.Command{
+		Use:   "copy [--block anchor] name",
+		Short: "Copy the snippet or one of its code blocks to the clipboard",
+		Long: `Copies the snippet (without its front-matter) or one of its code blocks to the clipboard. By default, it uses the
+OSC 52 terminal escape sequence which works over SSH too. Set SNIP_CLIPBOARD_CMD (e.g., "wl-copy") to use a command instead.`,
+		Args:              cobra.ExactArgs(1),
+		RunE:              CmdCopy,
+		ValidArgsFunction: cobraAutoCompleteFileName,
+	}
+
 	var dirCmd = &cobra.Command{
 		Use:   "dir [subPath]",
 		Short: "prints the snippets directory",
@@ -64,47 +162,278 @@ func run() error {
 		RunE:  CmdSnippetsDir,
 	}
 
+	var doctorCmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the environment and print fixes of the problems",
+		Long: `Checks the snippets dir, the viewer, editor and git commands, the git repository of the snippets dir and the
+shell completion, and prints actionable fixes of the problems. It exits with a non-zero code if any check fails.`,
+		Args: cobra.NoArgs,
+		RunE: CmdDoctor,
+	}
+
 	var editCmd = &cobra.Command{
-		Use:               "edit",
-		Short:             "Create|Edit the snippet in the editor",
-		Long:              "If do not provide any snippet name, it'll open the snippets directory in the editor",
+		Use:   "edit [--line N [--col N]] [name[:line[:col]]]",
+		Short: "Create|Edit the snippet in the editor",
+		Long: `If do not provide any snippet name, it'll open the snippets directory in the editor. Use 'name:42' (or
+'name:42:5') or the --line and --col flags to open the snippet at a location, e.g., a hit of the search command.`,
 		Args:              cobra.MaximumNArgs(1),
 		RunE:              CmdEditSnippet,
 		ValidArgsFunction: cobraAutoCompleteFileName,
 	}
 
-	var RemoveCmd = &cobra.Command{
-		Use:               "rm [-r] [file|dir(append a slash to it)]",
-		Short:             "Remove a snippet or directory",
-		Long:              `Removes a snippet file or a snippet directory. To specify a directory, append a '/' to it.`,
+	var listCmd = &cobra.Command{
+		Use:               "ls [subPath]",
+		Short:             "List snippets",
+		Long:              "List snippets of the snippets directory or one of its subdirectories as a flat list (default), a tree or in long format. Use --tag to filter snippets by their tags.",
 		Args:              cobra.MaximumNArgs(1),
+		RunE:              CmdList,
+		ValidArgsFunction: cobraAutoCompleteFileName,
+	}
+
+	var moveCmd = &cobra.Command{
+		Use:   "mv [--force] src dst",
+		Short: "Move or rename a snippet or directory",
+		Long: `Moves a snippet file or a snippet directory (append a '/' to directories) and rewrites relative markdown links
+and wiki-links of other snippets which point to it. It uses 'git mv' if the snippet is tracked by git.`,
+		Args:              cobra.ExactArgs(2),
+		RunE:              CmdMove,
+		ValidArgsFunction: cobraAutoCompleteFileName,
+	}
+
+	var profileCmd = &cobra.Command{
+		Use:   "profile",
+		Short: "Manage profiles",
+		Long: `Profiles are named sections of the config file (e.g., [tasks]) which let you keep separate snippet
+collections. Select a profile by the --profile flag or the SNIP_PROFILE env variable.`,
+	}
+
+	var profileListCmd = &cobra.Command{
+		Use:   "ls",
+		Short: "List profiles",
+		Args:  cobra.NoArgs,
+		RunE:  CmdProfileList,
+	}
+
+	var profileAddCmd = &cobra.Command{
+		Use:   "add name dir",
+		Short: "Add a profile with its snippets dir",
+		Args:  cobra.ExactArgs(2),
+		RunE:  CmdProfileAdd,
+	}
+
+	var profileRemoveCmd = &cobra.Command{
+		Use:               "rm name",
+		Short:             "Remove a profile from the config file, its snippets are kept",
+		Args:              cobra.ExactArgs(1),
+		RunE:              CmdProfileRemove,
+		ValidArgsFunction: cobraAutoCompleteProfile,
+	}
+
+	var RemoveCmd = &cobra.Command{
+		Use:   "rm [-r] [file|dir(append a slash to it)]",
+		Short: "Remove a snippet or directory",
+		Long: `Removes a snippet file or a snippet directory. To specify a directory, append a '/' to it.
+Removed snippets are moved to the trash, use the trash command to restore them.`,
+		Args:              cobra.ExactArgs(1),
 		RunE:              CmdRemoveSnippet,
 		ValidArgsFunction: cobraAutoCompleteFileName,
 	}
 
+	var runCmd = &cobra.Command{
+		Use:   "run [--block anchor] [--dry-run] [--yes] name[#anchor]",
+		Short: "Run shell code blocks of the snippet",
+		Long: `Runs shell code blocks (bash, sh, shell or zsh) of a markdown snippet, or the whole .sh snippet using your $SHELL.
+It shows the script and asks for confirmation before running it. The exit code of the script is propagated.`,
+		Args:              cobra.ExactArgs(1),
+		RunE:              CmdRun,
+		ValidArgsFunction: cobraAutoCompleteFileName,
+	}
+
+	var trashCmd = &cobra.Command{
+		Use:   "trash",
+		Short: "Manage removed snippets",
+	}
+
+	var trashListCmd = &cobra.Command{
+		Use:   "ls",
+		Short: "List removed snippets",
+		Args:  cobra.NoArgs,
+		RunE:  CmdTrashList,
+	}
+
+	var trashRestoreCmd = &cobra.Command{
+		Use:               "restore [--force] item",
+		Short:             "Restore a removed snippet to its original path",
+		Args:              cobra.ExactArgs(1),
+		RunE:              CmdTrashRestore,
+		ValidArgsFunction: cobraAutoCompleteTrashItem,
+	}
+
+	var trashEmptyCmd = &cobra.Command{
+		Use:   "empty [--older-than age]",
+		Short: "Permanently remove snippets of the trash",
+		Long:  "Permanently removes snippets of the trash. Use --older-than (e.g., 30d, 2w or 12h) to remove only old items.",
+		Args:  cobra.NoArgs,
+		RunE:  CmdTrashEmpty,
+	}
+
+	var statusCmd = &cobra.Command{
+		Use:   "status [--short]",
+		Short: "Show the snippets changes which are not synced yet",
+		Long: `Shows new, modified, deleted and conflicted snippets, and commits ahead/behind the upstream branch (as of the
+last fetch). Use --short to print a compact form for your shell prompt, e.g., '+1 ~2 -1 ↑1 ↓2'.`,
+		Args: cobra.NoArgs,
+		RunE: CmdStatus,
+	}
+
 	var syncCmd = &cobra.Command{
-		Use:   "sync",
+		Use:   "sync [--abort] [--remote name] [--branch name] [--strategy merge|rebase|ff-only] [--autostash] [message]",
 		Short: "sync the snippets changes with your remote git repository",
-		Long:  "Sync command first pull changes from yourb git repository and then commit and pushes your changes.",
-		Args:  cobra.MaximumNArgs(1),
-		RunE:  CmdSync,
+		Long: `Sync command commits your changes, pulls changes from your git repository (by merge, rebase or fast-forward
+only) and then pushes your changes. If the pull hits conflicts, it asks you how to resolve each conflicted snippet and
+then continues. If a sync is interrupted, run sync again to resume it, or 'sync --abort' to restore the state before
+the sync.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: CmdSync,
+	}
+
+	var indexCmd = &cobra.Command{
+		Use:   "index",
+		Short: "Update the snippets index",
+		Long:  "Update the on-disk index of the snippets which is used by auto-completion. Use --rebuild to rebuild it from scratch.",
+		Args:  cobra.NoArgs,
+		RunE:  CmdIndex,
+	}
+
+	var initCmd = &cobra.Command{
+		Use:   "init [--remote url | --clone url] [--force]",
+		Short: "Create the snippets repository",
+		Long: `Creates the snippets directory and makes it a git repository which you can sync: it clones the --clone repository
+into it, otherwise it initializes a repository with a .gitignore and README and commits them. With --remote (or
+--clone) it pushes the branch and sets its upstream branch. It refuses to init a non-empty directory unless you
+use --force, then your files are kept as local changes.`,
+		Args: cobra.NoArgs,
+		RunE: CmdInit,
+	}
+
+	var searchCmd = &cobra.Command{
+		Use:   "search [flags] query",
+		Short: "Search the snippets contents",
+		Long:  "Search lines of your snippets contents and print matches in 'path:line: excerpt' format. Snippets are sorted by their relevance to the query.",
+		Args:  cobra.ExactArgs(1),
+		PreRun: func(*cobra.Command, []string) {
+			if FlagSearchJSON { // --json is a shorthand for --output json
+				FlagOutput = OutputJSON
+			}
+		},
+		RunE: CmdSearch,
+	}
+
+	var tagsCmd = &cobra.Command{
+		Use:   "tags",
+		Short: "List tags of the snippets and their number of snippets",
+		Args:  cobra.NoArgs,
+		RunE:  CmdTags,
+	}
+
+	var useCmd = &cobra.Command{
+		Use:   "use [--var key=value]... name",
+		Short: "Fill in the snippet placeholders and print it",
+		Long: `Prints the snippet after filling in its placeholders. Placeholders are defined like {{name}}, {{name:default}}
+or {{name:choice1|choice2}}. Values of the placeholders which are not provided by --var will be asked interactively.`,
+		Args:              cobra.ExactArgs(1),
+		RunE:              CmdUse,
+		ValidArgsFunction: cobraAutoCompleteFileName,
 	}
 
 	var versionCmd = &cobra.Command{
 		Use:   "version",
 		Short: "Print the version and build information",
-		Run:   CmdPrintVersion,
+		RunE:  CmdPrintVersion,
 	}
 
+	rootCmd.Flags().BoolVar(&FlagShowFrontMatter, "front-matter", false, "Show the snippet's front-matter too")
+	rootCmd.Flags().StringVar(&FlagViewBlock, "block", "", "Print only the raw code of a code block by its index, info string or heading")
+	rootCmd.Flags().BoolVar(&FlagCopy, "copy", false, "Copy the snippet (or its code block) to the clipboard too")
+	_ = rootCmd.RegisterFlagCompletionFunc("block", cobraAutoCompleteBlockAnchor)
+	addCmd.Flags().BoolVarP(&FlagAddAppend, "append", "a", false, "Append to the snippet if it exists")
+	addCmd.Flags().BoolVarP(&FlagAddForce, "force", "f", false, "Overwrite the snippet without confirmation")
+	addCmd.Flags().StringVar(&FlagAddTitle, "title", "", "Prepend a heading with the title")
+	addCmd.MarkFlagsMutuallyExclusive("append", "force")
+	copyCmd.Flags().StringVar(&FlagCopyBlock, "block", "", "Copy only the raw code of a code block by its index, info string or heading")
+	_ = copyCmd.RegisterFlagCompletionFunc("block", cobraAutoCompleteBlockAnchor)
+	rootCmd.PersistentFlags().StringVarP(&FlagOutput, "output", "o", OutputText, "Output format: text, json or yaml")
+	rootCmd.PersistentFlags().StringVar(&FlagConfigFile, "config", "", "Config file path (default $XDG_CONFIG_HOME/snip/config.toml)")
+	_ = rootCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(outputFormats, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.PersistentFlags().StringVar(&FlagProfile, "profile", "", "Profile name (default $SNIP_PROFILE or the app name)")
+	_ = rootCmd.RegisterFlagCompletionFunc("profile", cobraAutoCompleteProfile)
+	configSetCmd.Flags().BoolVar(&FlagConfigGlobal, "global", false, "Set it in the global section of the config file")
+	configCmd.AddCommand(configListCmd, configGetCmd, configSetCmd, configEditCmd)
+	cpCmd.Flags().BoolVarP(&FlagRecursiveCopy, "recursive", "r", false, "Copy directories recursively")
+	cpCmd.Flags().BoolVarP(&FlagForceCopy, "force", "f", false, "Overwrite the destination if it exists")
+	cpCmd.Flags().StringArrayVar(&FlagCopyVars, "set", nil, "Fill in a placeholder in the key=value format (can be repeated)")
+	_ = cpCmd.RegisterFlagCompletionFunc("set", cobraAutoCompletePlaceholder)
+	moveCmd.Flags().BoolVarP(&FlagMoveForce, "force", "f", false, "Overwrite the destination if it exists")
+	profileCmd.AddCommand(profileListCmd, profileAddCmd, profileRemoveCmd)
 	RemoveCmd.Flags().BoolVarP(&FlagRecursiveRemove, "recursive", "r", false, "Remove recursively")
-	rootCmd.AddCommand(completionCmd, dirCmd, editCmd, RemoveCmd, syncCmd, versionCmd)
-
-	return rootCmd.Execute()
+	RemoveCmd.Flags().BoolVarP(&FlagRemoveYes, "yes", "y", false, "Remove recursively without confirmation")
+	trashRestoreCmd.Flags().BoolVarP(&FlagForceRestore, "force", "f", false, "Move the existing snippet of the original path to the trash and restore the item")
+	trashEmptyCmd.Flags().StringVar(&FlagTrashOlderThan, "older-than", "", "Remove only items which are removed before this age (e.g., 30d)")
+	trashCmd.AddCommand(trashListCmd, trashRestoreCmd, trashEmptyCmd)
+	searchCmd.Flags().BoolVarP(&FlagSearchRegex, "regex", "E", false, "Interpret the query as a regular expression")
+	searchCmd.Flags().BoolVarP(&FlagSearchCaseSensitive, "case-sensitive", "s", false, "Search case sensitively")
+	searchCmd.Flags().StringVarP(&FlagSearchDir, "dir", "d", "", "Limit the search to a subdirectory")
+	searchCmd.Flags().BoolVar(&FlagSearchJSON, "json", false, "Print results and their scores in JSON format")
+	_ = searchCmd.RegisterFlagCompletionFunc("dir", cobraAutoCompleteFileName)
+	editCmd.Flags().IntVar(&FlagEditLine, "line", 0, "Open the snippet at the line (starting from 1)")
+	editCmd.Flags().IntVar(&FlagEditCol, "col", 0, "Open the snippet at the column of the line (starting from 1)")
+	statusCmd.Flags().BoolVarP(&FlagStatusShort, "short", "s", false, "Print the status in a compact form for shell prompts")
+	syncCmd.Flags().BoolVar(&FlagSyncAbort, "abort", false, "Abort the interrupted sync and restore the state before it")
+	syncCmd.Flags().StringVar(&FlagSyncRemote, "remote", "", "The git remote to sync with (default is the sync_remote setting)")
+	syncCmd.Flags().StringVar(&FlagSyncBranch, "branch", "", "The remote branch to sync with (default is the sync_branch setting, otherwise the upstream branch)")
+	syncCmd.Flags().StringVar(&FlagSyncStrategy, "strategy", "", "The pull strategy: merge, rebase or ff-only (default is the sync_strategy setting)")
+	syncCmd.Flags().BoolVar(&FlagSyncAutostash, "autostash", false, "Stash uncommitted changes before the pull and apply them after it (just with the ff-only strategy)")
+	_ = syncCmd.RegisterFlagCompletionFunc("strategy", cobra.FixedCompletions(syncStrategies, cobra.ShellCompDirectiveNoFileComp))
+	indexCmd.Flags().BoolVar(&FlagRebuildIndex, "rebuild", false, "Rebuild the index from scratch")
+	initCmd.Flags().StringVar(&FlagInitRemote, "remote", "", "The URL of the remote repository to push the new repository to")
+	initCmd.Flags().StringVar(&FlagInitClone, "clone", "", "The URL of an existing snippets repository to clone")
+	initCmd.Flags().BoolVar(&FlagInitForce, "force", false, "Init the snippets directory even if it's not empty")
+	initCmd.MarkFlagsMutuallyExclusive("remote", "clone")
+	listCmd.Flags().StringArrayVarP(&FlagListTags, "tag", "t", nil, "List snippets which have the tag (can be repeated)")
+	listCmd.Flags().BoolVar(&FlagListTree, "tree", false, "Print snippets as an indented tree")
+	listCmd.Flags().BoolVarP(&FlagListLong, "long", "l", false, "Print size, modification time, git status and title of snippets")
+	listCmd.Flags().StringVarP(&FlagListSort, "sort", "s", "name", "Sort by name, mtime or size")
+	listCmd.Flags().IntVarP(&FlagListDepth, "depth", "d", 0, "Max depth of directories to descend (0 means unlimited)")
+	listCmd.MarkFlagsMutuallyExclusive("tree", "long")
+	_ = listCmd.RegisterFlagCompletionFunc("tag", cobraAutoCompleteTag)
+	_ = listCmd.RegisterFlagCompletionFunc("sort", cobra.FixedCompletions(listSortOrders, cobra.ShellCompDirectiveNoFileComp))
+	useCmd.Flags().StringArrayVar(&FlagUseVars, "var", nil, "Value of a placeholder in the key=value format (can be repeated)")
+	_ = useCmd.RegisterFlagCompletionFunc("var", cobraAutoCompletePlaceholder)
+	runCmd.Flags().StringVar(&FlagRunBlock, "block", "", "Run only a shell code block by its index, info string or heading")
+	runCmd.Flags().BoolVar(&FlagRunDryRun, "dry-run", false, "Print the script without running it")
+	runCmd.Flags().BoolVarP(&FlagRunYes, "yes", "y", false, "Run without confirmation")
+	_ = runCmd.RegisterFlagCompletionFunc("block", cobraAutoCompleteShellBlockAnchor)
+	rootCmd.AddCommand(addCmd, completionCmd, configCmd, copyCmd, cpCmd, dirCmd, doctorCmd, editCmd, indexCmd, initCmd, listCmd, moveCmd, profileCmd, RemoveCmd, runCmd, searchCmd, statusCmd, syncCmd, tagsCmd, trashCmd, useCmd, versionCmd)
+	return rootCmd
 }
 
 // boot boots the app. it loads config for us.
 func boot(cmd *cobra.Command, _ []string) error {
-	if err := loadConfig(prefix, strings.ToUpper(cmd.Root().Name())); err != nil {
+	if !slices.Contains(outputFormats, FlagOutput) {
+		return invalidArgument("invalid output format: %s, valid values: %s", FlagOutput, strings.Join(outputFormats, ", "))
+	}
+
+	// In structured output formats, we print errors as structured documents ourselves.
+	if isStructuredOutput() {
+		cmd.Root().SilenceErrors, cmd.Root().SilenceUsage = true, true
+	}
+
+	profile, err := profileName(cmd.Root().Name())
+	if err != nil {
+		return err
+	}
+
+	if err := loadConfig(prefix, strings.ToUpper(profile)); err != nil {
 		return err
 	}
 
@@ -123,26 +452,128 @@ func shutdown(_ *cobra.Command, _ []string) error {
 }
 
 func cobraAutoCompleteFileName(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	exclude := Cfg.Exclude
+	// Complete code block anchors. Like splitBlockAnchor, the anchor follows the last '#' of a snippet's name.
+	if i := strings.LastIndex(toComplete, "#"); i != -1 {
+		if _, err := os.Stat(Cfg.SnippetPath(toComplete[:i])); err == nil {
+			return completeBlockAnchors(toComplete[:i], toComplete[:i+1], toComplete[i+1:], nil)
+		}
+	}
+
 	searchDir := filepath.Dir(toComplete)
-	root := path.Join(Cfg.Dir, searchDir)
+	res, idx, err := findSnippets(searchDir, baseName(toComplete), Cfg.Exclude)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	return withDescriptions(res, idx), cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+func cobraAutoCompleteBlockAnchor(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeBlockAnchors(args[0], "", toComplete, nil)
+}
+
+func cobraAutoCompleteShellBlockAnchor(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeBlockAnchors(args[0], "", toComplete, isShellBlock)
+}
+
+// completeBlockAnchors returns the code block anchors of the snippet which start with the toComplete.
+func completeBlockAnchors(name string, prefix string, toComplete string, filter func(b codeBlock) bool) ([]string, cobra.ShellCompDirective) {
+	blocks, err := readSnippetBlocks(Cfg.SnippetPath(name))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var res []string
+	for _, anchor := range blockAnchors(blocks, filter) {
+		if strings.HasPrefix(anchor, toComplete) {
+			res = append(res, prefix+anchor)
+		}
+	}
+	return res, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+func cobraAutoCompleteTrashItem(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	items, err := listTrash()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	res := make([]string, len(items))
+	for i, item := range items {
+		res[i] = item.ID + "\t" + item.Path
+	}
+	return res, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+func cobraAutoCompleteConfigKey(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	res := make([]string, len(Cfg.settings))
+	for i, s := range Cfg.settings {
+		res[i] = s.Key + "\t" + s.Value
+	}
+	return res, cobra.ShellCompDirectiveNoFileComp
+}
+
+func cobraAutoCompleteProfile(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return Cfg.file.sections(), cobra.ShellCompDirectiveNoFileComp
+}
+
+func cobraAutoCompleteTag(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	keys, err := listSnippets(Cfg.Dir, "", Cfg.Exclude)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	tags, err := loadSnippetsTags(keys)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var res []string
+	for _, tc := range countTags(tags) {
+		res = append(res, tc.Tag)
+	}
+	return res, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
 
-	if searchDir != "." && searchDir != "/" { // Currently we support exclude only on the root dir.
-		exclude = nil
+func cobraAutoCompletePlaceholder(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	res, err := findFiles(root, baseName(toComplete), exclude, searchDir)
+	text, err := readSnippetTemplate(args[0])
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
 	}
 
+	var res []string
+	for _, p := range parsePlaceholders(text) {
+		res = append(res, p.Name+"="+p.Default)
+	}
 	return res, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
 }
 
 func CmdCompletionGenerator(cmd *cobra.Command, args []string) error {
+	var script bytes.Buffer
 	switch args[0] {
 	case "bash":
-		if err := cmd.Root().GenBashCompletionV2(os.Stdout, true); err != nil {
+		if err := cmd.Root().GenBashCompletionV2(&script, true); err != nil {
 			return err
 		}
 		// Currently in bash we do not support fzf, because when we define the fzf function and enable completion
@@ -151,28 +582,50 @@ func CmdCompletionGenerator(cmd *cobra.Command, args []string) error {
 		// TODO: enable completion for bash, but keep the original completion too.
 		//fmt.Println(genFzfBashCompletion(cmd.Root().Name()))
 	case "zsh":
-		if err := cmd.Root().GenZshCompletion(os.Stdout); err != nil {
+		if err := cmd.Root().GenZshCompletion(&script); err != nil {
 			return err
 		}
-		fmt.Print("\n\n")
+		script.WriteString("\n\n")
 
 		// In zsh, we support fzf too:
-		fmt.Println(genFzfZshCompletion(cmd.Root().Name()))
+		script.WriteString(genFzfZshCompletion(cmd.Root().Name()) + "\n")
 	case "fish":
-		if err := cmd.Root().GenFishCompletion(os.Stdout, true); err != nil {
+		if err := cmd.Root().GenFishCompletion(&script, true); err != nil {
 			return err
 		}
 	case "powershell":
-		if err := cmd.Root().GenPowerShellCompletionWithDesc(os.Stdout); err != nil {
+		if err := cmd.Root().GenPowerShellCompletionWithDesc(&script); err != nil {
 			return err
 		}
 	}
-	return nil
+
+	// Wrapper functions of profiles, e.g., 'tasks' runs snip with the tasks profile and completes like snip.
+	script.WriteString(genProfilesCompletion(args[0], cmd.Root().Name()))
+
+	return printOutput(os.Stdout, CompletionDoc{Shell: args[0], Script: script.String()}, func(w io.Writer) error {
+		_, err := script.WriteTo(w)
+		return err
+	})
 }
 
 func CmdViewSnippet(c *cobra.Command, args []string) error {
+	if FlagCopy {
+		name, anchor := splitBlockAnchor(args[0])
+		if _, err := copySnippet(name, DefaultStr(FlagViewBlock, anchor)); err != nil {
+			return err
+		}
+	}
+
+	if name, anchor := splitBlockAnchor(args[0]); anchor != "" || FlagViewBlock != "" {
+		return printCodeBlock(c, name, DefaultStr(FlagViewBlock, anchor))
+	}
+
 	fpath := Cfg.SnippetPath(args[0])
 
+	if isStructuredOutput() {
+		return printSnippetDoc(args[0], fpath)
+	}
+
 	// If the file doesn't exist and is not a directory path, ask for creating it.
 	if _, err := os.Stat(fpath); errors.Is(err, os.ErrNotExist) && !EndsWithDirectoryPath(fpath) {
 		relativeFname := strings.TrimPrefix(fpath, Cfg.Dir+string(os.PathSeparator))
@@ -184,84 +637,927 @@ func CmdViewSnippet(c *cobra.Command, args []string) error {
 
 		return CmdEditSnippet(c, args)
 	}
-	return Cfg.ViewerCmd(fpath).Run()
+
+	if FlagShowFrontMatter || EndsWithDirectoryPath(fpath) {
+		return Cfg.ViewerCmd(fpath).Run()
+	}
+
+	// Strip the front-matter before viewing the snippet.
+	bodyPath, cleanup, err := snippetBodyFile(fpath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return Cfg.ViewerCmd(bodyPath).Run()
 }
 
-func CmdSnippetsDir(_ *cobra.Command, args []string) error {
-	subPath := ""
-	if len(args) != 0 {
-		subPath = filepath.Dir(args[0])
+// printCodeBlock prints raw code of the snippet's code block, so it can be piped to other commands.
+func printCodeBlock(c *cobra.Command, name string, anchor string) error {
+	blocks, err := readSnippetBlocks(Cfg.SnippetPath(name))
+	if err != nil {
+		return err
 	}
 
-	fmt.Println(filepath.Join(Cfg.Dir, subPath))
-	return nil
+	block, index, err := findBlock(blocks, anchor)
+	if err != nil {
+		return err
+	}
+
+	doc := CodeBlockDoc{Name: name, Index: index, Info: block.Info, Heading: block.Heading, Code: block.Code}
+	return printOutput(c.OutOrStdout(), doc, func(w io.Writer) error {
+		_, err := io.WriteString(w, block.Code)
+		return err
+	})
 }
 
-func CmdEditSnippet(_ *cobra.Command, args []string) error {
-	fpath := Cfg.Dir
-	if len(args) != 0 {
-		fpath = Cfg.SnippetPath(args[0])
+// printSnippetDoc prints the snippet's metadata and its body as a structured document.
+func printSnippetDoc(name string, fpath string) error {
+	b, err := os.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
 
-		// Make parent directories
-		if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
-			return fmt.Errorf("can not create snippet directory: %w", err)
-		}
+	meta, body, err := parseSnippet(fpath, b)
+	if err != nil {
+		return err
 	}
 
-	return Command(Cfg.Editor, fpath).Run()
+	rel, err := filepath.Rel(Cfg.Dir, fpath)
+	if err != nil {
+		rel = name
+	}
+	doc := SnippetDoc{Name: snippetName(filepath.ToSlash(rel)), Path: fpath, Meta: meta, Body: string(body)}
+	return printOutput(os.Stdout, doc, noText)
 }
 
-func CmdRemoveSnippet(_ *cobra.Command, args []string) error {
+func CmdAdd(c *cobra.Command, args []string) error {
 	fpath := Cfg.SnippetPath(args[0])
+	if EndsWithDirectoryPath(fpath) {
+		return invalidArgument("%s is a directory, provide a snippet name", args[0])
+	}
+
+	input, err := io.ReadAll(c.InOrStdin())
+	if err != nil {
+		return fmt.Errorf("can not read stdin: %w", err)
+	}
+	if len(bytes.TrimSpace(input)) == 0 {
+		return invalidArgument("nothing to add, stdin is empty")
+	}
 
-	f := os.Remove
-	if FlagRecursiveRemove {
-		f = os.RemoveAll
+	if _, err := os.Stat(fpath); err == nil && !FlagAddAppend && !FlagAddForce {
+		ok, err := confirmOverwrite(args[0])
+		if err != nil || !ok {
+			return err
+		}
 	}
-	if err := f(fpath); err != nil {
+
+	contents := addContents(input, FlagAddTitle)
+	if err := writeSnippet(fpath, contents, FlagAddAppend); err != nil {
 		return err
 	}
 
-	fmt.Printf("Removed: %s\n", fpath)
-	return nil
+	return printOutput(c.OutOrStdout(), AddDoc{Path: fpath, Size: len(contents), Appended: FlagAddAppend}, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Saved: %s\n", fpath)
+		return err
+	})
 }
 
-func CmdSync(_ *cobra.Command, args []string) error {
-	msg := "snip: Update snippets"
-	if len(args) > 0 {
-		msg = args[0]
+func CmdConfigList(c *cobra.Command, _ []string) error {
+	docs := make([]ConfigSettingDoc, len(Cfg.settings))
+	for i, s := range Cfg.settings {
+		docs[i] = ConfigSettingDoc(s)
+	}
+
+	return printOutput(c.OutOrStdout(), docs, func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		if _, err := fmt.Fprintf(tw, "KEY\tVALUE\tSOURCE\n"); err != nil {
+			return err
+		}
+		for _, s := range Cfg.settings {
+			if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, s.Value, s.Source); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(tw, "\nConfig file: %s\n", Cfg.ConfigFile); err != nil {
+			return err
+		}
+		return tw.Flush()
+	})
+}
+
+func CmdConfigGet(c *cobra.Command, args []string) error {
+	s, err := configSettingByKey(args[0])
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("pull new changes")
-	if err := Command(Cfg.Git, "-C", Cfg.Dir, "pull", "origin").Run(); err != nil {
+	return printOutput(c.OutOrStdout(), ConfigSettingDoc(s), func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, s.Value)
+		return err
+	})
+}
+
+func CmdConfigSet(c *cobra.Command, args []string) error {
+	current, err := configSettingByKey(args[0])
+	if err != nil {
 		return err
 	}
 
-	fmt.Println("Add new changes to the git index")
-	if err := Command(Cfg.Git, "-C", Cfg.Dir, "add", "-A").Run(); err != nil {
+	doc := ConfigSettingDoc{Key: args[0], Value: args[1], Source: "config file"}
+	section := ""
+	if !FlagConfigGlobal {
+		section = Cfg.Profile
+		doc.Source += " [" + section + "]"
+	}
+	if err := writeConfigValue(section, args[0], args[1]); err != nil {
 		return err
 	}
 
-	var exitErr *exec.ExitError
-	if err := Command(Cfg.Git, "-C", Cfg.Dir, "diff", "HEAD", "--quiet").Run(); err == nil {
-		fmt.Println("You don't have any changes since last push")
+	return printOutput(c.OutOrStdout(), doc, func(w io.Writer) error {
+		if _, err := fmt.Fprintf(w, "Set %s in %s\n", args[0], Cfg.ConfigFile); err != nil {
+			return err
+		}
+		if strings.HasPrefix(current.Source, "env ") { // Env variables take precedence over the config file.
+			_, err := fmt.Fprintf(w, "Note: the %s env variable overrides it\n", strings.TrimPrefix(current.Source, "env "))
+			return err
+		}
 		return nil
-	} else if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+	})
+}
+
+func CmdConfigEdit(_ *cobra.Command, _ []string) error {
+	if err := os.MkdirAll(filepath.Dir(Cfg.ConfigFile), 0700); err != nil {
+		return fmt.Errorf("can not create the config directory: %w", err)
+	}
+	args := editorArgs(Cfg.EditorCMD, Cfg.ConfigFile, 0, 0)
+	return Command(args[0], args[1:]...).Run()
+}
+
+func CmdProfileList(c *cobra.Command, _ []string) error {
+	profiles, err := listProfiles()
+	if err != nil {
+		return err
+	}
+
+	docs := make([]ProfileDoc, len(profiles))
+	for i, p := range profiles {
+		docs[i] = profileDoc(p)
+	}
+
+	return printOutput(c.OutOrStdout(), docs, func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, p := range profiles {
+			mark := " "
+			if p.Name == Cfg.Profile {
+				mark = "*"
+			}
+			if _, err := fmt.Fprintf(tw, "%s %s\t%s\n", mark, p.Name, p.Dir); err != nil {
+				return err
+			}
+		}
+		return tw.Flush()
+	})
+}
+
+func CmdProfileAdd(c *cobra.Command, args []string) error {
+	p, err := addProfile(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	return printOutput(c.OutOrStdout(), profileDoc(p), func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Added profile %s: %s\n", p.Name, p.Dir)
+		return err
+	})
+}
+
+func CmdProfileRemove(c *cobra.Command, args []string) error {
+	p, err := removeProfile(args[0])
+	if err != nil {
 		return err
 	}
 
-	fmt.Println("commit new changes")
-	err := Command(Cfg.Git, "-C", Cfg.Dir, "commit", "-m", msg).Run()
+	return printOutput(c.OutOrStdout(), profileDoc(p), func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Removed profile %s, its snippets are kept in %s\n", p.Name, p.Dir)
+		return err
+	})
+}
+
+func profileDoc(p profile) ProfileDoc {
+	return ProfileDoc{Name: p.Name, Dir: p.Dir, Current: p.Name == Cfg.Profile}
+}
+
+func CmdCopy(c *cobra.Command, args []string) error {
+	name, anchor := splitBlockAnchor(args[0])
+	doc, err := copySnippet(name, DefaultStr(FlagCopyBlock, anchor))
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("Push changes")
-	return Command(Cfg.Git, "-C", Cfg.Dir, "push", "origin").Run()
+	return printOutput(c.OutOrStdout(), doc, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Copied %s to the clipboard\n", args[0])
+		return err
+	})
+}
+
+// copySnippet copies the snippet or its code block to the clipboard.
+func copySnippet(name string, anchor string) (CopyDoc, error) {
+	text, err := snippetText(name, anchor)
+	if err != nil {
+		return CopyDoc{}, err
+	}
+
+	backend, err := copyToClipboard(text)
+	if err != nil {
+		return CopyDoc{}, fmt.Errorf("can not copy to the clipboard: %w", err)
+	}
+	return CopyDoc{Name: name, Size: len(text), Backend: backend}, nil
+}
+
+func CmdSnippetsDir(_ *cobra.Command, args []string) error {
+	subPath := ""
+	if len(args) != 0 {
+		subPath = filepath.Dir(args[0])
+	}
+
+	dir := filepath.Join(Cfg.Dir, subPath)
+	return printOutput(os.Stdout, DirDoc{Path: dir}, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, dir)
+		return err
+	})
 }
 
-func CmdPrintVersion(*cobra.Command, []string) {
-	fmt.Println("Version: ", DefaultStr(Version, "unknown"))
-	fmt.Println("Commit: ", DefaultStr(Commit, "unknown"))
-	fmt.Println("Build Date: ", DefaultStr(Date, "unknown"))
+func CmdDoctor(c *cobra.Command, _ []string) error {
+	checks := doctorChecks(c.Root().Name())
+
+	failed, warnings := 0, 0
+	docs := make([]DoctorCheckDoc, len(checks))
+	for i, ch := range checks {
+		docs[i] = DoctorCheckDoc(ch)
+		switch ch.Status {
+		case CheckFail:
+			failed++
+		case CheckWarn:
+			warnings++
+		}
+	}
+
+	err := printOutput(c.OutOrStdout(), docs, func(w io.Writer) error {
+		for _, ch := range checks {
+			if _, err := fmt.Fprintf(w, "%-6s %s: %s\n", "["+ch.Status+"]", ch.Name, ch.Message); err != nil {
+				return err
+			}
+			if ch.Fix != "" {
+				if _, err := fmt.Fprintf(w, "       Fix: %s\n", ch.Fix); err != nil {
+					return err
+				}
+			}
+		}
+		_, err := fmt.Fprintf(w, "\n%d failed, %d warning(s)\n", failed, warnings)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if failed != 0 {
+		c.SilenceErrors, c.SilenceUsage = true, true
+		return errChecksFailed
+	}
+	return nil
+}
+
+func CmdEditSnippet(_ *cobra.Command, args []string) error {
+	fpath, line, col := Cfg.Dir, FlagEditLine, FlagEditCol
+	if len(args) != 0 {
+		name, nameLine, nameCol := splitLocation(args[0])
+		fpath = Cfg.SnippetPath(name)
+		if line == 0 { // The flags take precedence over the location of the name.
+			line = nameLine
+		}
+		if col == 0 {
+			col = nameCol
+		}
+
+		// Make parent directories
+		if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+			return fmt.Errorf("can not create snippet directory: %w", err)
+		}
+	}
+
+	editor := editorArgs(Cfg.EditorCMD, fpath, line, col)
+	return Command(editor[0], editor[1:]...).Run()
+}
+
+func CmdList(c *cobra.Command, args []string) error {
+	if !slices.Contains(listSortOrders, FlagListSort) {
+		return invalidArgument("invalid sort order: %s, valid values: %s", FlagListSort, strings.Join(listSortOrders, ", "))
+	}
+
+	subPath := ""
+	if len(args) != 0 {
+		subPath = args[0]
+	}
+
+	tree, err := readListTree(Cfg.Dir, subPath, Cfg.Exclude)
+	if err != nil {
+		return err
+	}
+
+	if len(FlagListTags) != 0 {
+		var keys []string
+		for _, e := range flattenListTree(tree) {
+			keys = append(keys, e.Key)
+		}
+
+		tags, err := loadSnippetsTags(keys)
+		if err != nil {
+			return err
+		}
+		filterListTree(tree, func(e *listEntry) bool {
+			return hasTags(tags[e.Key], FlagListTags)
+		})
+	}
+
+	truncateListTree(tree, FlagListDepth)
+	sortListTree(tree, FlagListSort)
+
+	if FlagListTree && !isStructuredOutput() {
+		return printListTree(c.OutOrStdout(), tree, DefaultStr(subPath, "."))
+	}
+
+	entries := flattenListTree(tree)
+	if FlagListSort != "name" {
+		slices.SortStableFunc(entries, compareListEntries(FlagListSort))
+	}
+
+	var statuses map[string]string
+	if FlagListLong || isStructuredOutput() {
+		if statuses, err = gitFileStatuses(); err != nil {
+			Verbose("can not get git status of the snippets: ", err)
+		}
+	}
+
+	return printOutput(c.OutOrStdout(), listItemDocs(Cfg.Dir, entries, statuses), func(w io.Writer) error {
+		if FlagListLong {
+			return printListLong(w, Cfg.Dir, entries, statuses)
+		}
+
+		for _, e := range entries {
+			if _, err := fmt.Fprintln(w, listEntryName(e, false)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func CmdTags(c *cobra.Command, _ []string) error {
+	keys, err := listSnippets(Cfg.Dir, "", Cfg.Exclude)
+	if err != nil {
+		return err
+	}
+
+	tags, err := loadSnippetsTags(keys)
+	if err != nil {
+		return err
+	}
+
+	counts := countTags(tags)
+	return printOutput(c.OutOrStdout(), counts, func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, tc := range counts {
+			if _, err := fmt.Fprintf(tw, "%s\t%d\n", tc.Tag, tc.Count); err != nil {
+				return err
+			}
+		}
+		return tw.Flush()
+	})
+}
+
+func CmdCp(c *cobra.Command, args []string) error {
+	values, err := parseVars(FlagCopyVars)
+	if err != nil {
+		return err
+	}
+
+	m, err := resolveSnippetMove(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	if m.IsDir && !FlagRecursiveCopy {
+		return invalidArgument("%s is a directory, use -r to copy it recursively", args[0])
+	}
+
+	src, dst := filepath.Join(Cfg.Dir, filepath.FromSlash(m.From)), filepath.Join(Cfg.Dir, filepath.FromSlash(m.To))
+	if _, err := os.Stat(dst); err == nil && !FlagForceCopy {
+		return fmt.Errorf("%s: %w, use --force to overwrite it", m.To, os.ErrExist)
+	}
+
+	files, err := copySnippetFiles(m, values)
+	if err != nil {
+		return err
+	}
+
+	return printOutput(c.OutOrStdout(), DuplicateDoc{From: src, To: dst, Files: files}, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Copied: %s -> %s\n", m.From, m.To)
+		return err
+	})
+}
+
+func CmdMove(c *cobra.Command, args []string) error {
+	m, err := resolveSnippetMove(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	src, dst := filepath.Join(Cfg.Dir, filepath.FromSlash(m.From)), filepath.Join(Cfg.Dir, filepath.FromSlash(m.To))
+	move := moveSnippetFile
+	if _, err := os.Stat(dst); err == nil {
+		if !FlagMoveForce {
+			return fmt.Errorf("%s: %w, use --force to overwrite it", m.To, os.ErrExist)
+		}
+		move = replaceSnippetFile
+	}
+
+	git, err := move(m)
+	if err != nil {
+		return err
+	}
+
+	updated, err := rewriteSnippetsLinks(m)
+	if err != nil {
+		return fmt.Errorf("moved the snippet, but can not rewrite links: %w", err)
+	}
+
+	doc := MoveDoc{From: src, To: dst, Git: git, UpdatedLinks: append([]string{}, updated...)}
+	return printOutput(c.OutOrStdout(), doc, func(w io.Writer) error {
+		if _, err := fmt.Fprintf(w, "Moved: %s -> %s\n", m.From, m.To); err != nil {
+			return err
+		}
+		for _, key := range updated {
+			if _, err := fmt.Fprintf(w, "Updated links: %s\n", key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func CmdRemoveSnippet(c *cobra.Command, args []string) error {
+	fpath := Cfg.SnippetPath(args[0])
+	info, err := os.Stat(fpath)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(fpath)
+		if err != nil {
+			return err
+		}
+		if len(entries) != 0 && !FlagRecursiveRemove {
+			return invalidArgument("%s is not empty, use -r to remove it recursively", args[0])
+		}
+
+		if len(entries) != 0 && !FlagRemoveYes {
+			msg := fmt.Sprintf("Remove %s and all of its contents? (y/n) [n] ", args[0])
+			ok, err := ConfirmPrompt(c.InOrStdin(), c.ErrOrStderr(), msg)
+			if err != nil || !ok {
+				return err
+			}
+		}
+	}
+
+	rel, err := filepath.Rel(Cfg.Dir, filepath.Clean(fpath))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return invalidArgument("can not remove %s, it's not inside the snippets directory", fpath)
+	}
+
+	doc := RemoveDoc{Path: fpath, Recursive: FlagRecursiveRemove}
+	if Cfg.TrashDir == "" {
+		err = os.RemoveAll(fpath)
+	} else {
+		var item trashItem
+		item, err = moveToTrash(filepath.ToSlash(rel), time.Now())
+		doc.TrashID = item.ID
+	}
+	if err != nil {
+		return err
+	}
+
+	return printOutput(os.Stdout, doc, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Removed: %s\n", fpath)
+		return err
+	})
+}
+
+func CmdTrashList(c *cobra.Command, _ []string) error {
+	items, err := listTrash()
+	if err != nil {
+		return err
+	}
+
+	docs := make([]TrashItemDoc, len(items))
+	for i, item := range items {
+		docs[i] = trashItemDoc(item)
+	}
+
+	return printOutput(c.OutOrStdout(), docs, func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, item := range items {
+			name := snippetName(item.Path)
+			if item.IsDir {
+				name = filepath.FromSlash(item.Path) + "/"
+			}
+			if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\n", item.ID, item.DeletedAt.Local().Format("2006-01-02 15:04"), name); err != nil {
+				return err
+			}
+		}
+		return tw.Flush()
+	})
+}
+
+func CmdTrashRestore(c *cobra.Command, args []string) error {
+	item, err := restoreTrashItem(args[0], FlagForceRestore)
+	if err != nil {
+		return err
+	}
+
+	return printOutput(c.OutOrStdout(), trashItemDoc(item), func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Restored: %s\n", item.Path)
+		return err
+	})
+}
+
+func CmdTrashEmpty(c *cobra.Command, _ []string) error {
+	before := time.Now()
+	if FlagTrashOlderThan != "" {
+		age, err := parseAge(FlagTrashOlderThan)
+		if err != nil {
+			return err
+		}
+		before = before.Add(-age)
+	}
+
+	items, err := emptyTrash(before)
+	if err != nil {
+		return err
+	}
+
+	docs := make([]TrashItemDoc, len(items))
+	for i, item := range items {
+		docs[i] = trashItemDoc(item)
+	}
+	return printOutput(c.OutOrStdout(), docs, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Removed %d item(s) permanently\n", len(items))
+		return err
+	})
+}
+
+func trashItemDoc(item trashItem) TrashItemDoc {
+	return TrashItemDoc{ID: item.ID, Path: item.Path, IsDir: item.IsDir, DeletedAt: item.DeletedAt.Format(time.RFC3339)}
+}
+
+func CmdIndex(_ *cobra.Command, _ []string) error {
+	if Cfg.IndexFile == "" {
+		return errors.New("the index file path is empty")
+	}
+
+	if FlagRebuildIndex {
+		if err := os.Remove(Cfg.IndexFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	idx, err := loadIndex(true)
+	if err != nil {
+		return err
+	}
+
+	return printOutput(os.Stdout, IndexDoc{File: Cfg.IndexFile, Entries: len(idx.Entries)}, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Indexed %d entries: %s\n", len(idx.Entries), Cfg.IndexFile)
+		return err
+	})
+}
+
+func CmdInit(c *cobra.Command, _ []string) error {
+	res, err := initSnippetsRepo(FlagInitRemote, FlagInitClone, FlagInitForce)
+	if err != nil {
+		return err
+	}
+
+	return printOutput(c.OutOrStdout(), InitDoc(res), func(w io.Writer) error {
+		msg := "Initialized the snippets repository in " + Cfg.Dir
+		if res.Cloned {
+			msg = fmt.Sprintf("Cloned %s into %s", res.Remote, Cfg.Dir)
+		}
+		if res.Pushed {
+			msg += fmt.Sprintf(", and pushed the %s branch to %s", res.Branch, res.Remote)
+		}
+		_, err := fmt.Fprintln(w, msg)
+		return err
+	})
+}
+
+func CmdRun(c *cobra.Command, args []string) error {
+	name, anchor := splitBlockAnchor(args[0])
+	script, err := snippetScript(name, DefaultStr(FlagRunBlock, anchor))
+	if err != nil {
+		return err
+	}
+
+	doc := RunDoc{Name: args[0], Shell: userShell(), Script: script, DryRun: FlagRunDryRun}
+	if FlagRunDryRun {
+		return printOutput(c.OutOrStdout(), doc, func(w io.Writer) error {
+			_, err := io.WriteString(w, script)
+			return err
+		})
+	}
+
+	if !FlagRunYes {
+		if _, err := io.WriteString(c.ErrOrStderr(), scriptPreview(doc.Shell, script)); err != nil {
+			return err
+		}
+		ok, err := BoolPrompt(c.InOrStdin(), c.ErrOrStderr(), "Run it? (y/n) [y] ")
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	cmd := Command(doc.Shell, "-c", script)
+	if isStructuredOutput() { // Keep stdout parsable.
+		cmd.Stdout = os.Stderr
+	}
+
+	// From now on, the script prints its own errors, so we just propagate its exit code.
+	c.SilenceErrors, c.SilenceUsage = true, true
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); errors.As(err, &exitErr) {
+		return &runError{err: exitErr}
+	} else if err != nil {
+		return err
+	}
+
+	return printOutput(c.OutOrStdout(), doc, noText)
+}
+
+func CmdSearch(c *cobra.Command, args []string) error {
+	pattern, err := newSearchPattern(args[0], FlagSearchRegex, FlagSearchCaseSensitive)
+	if err != nil {
+		return err
+	}
+
+	matches, err := searchFiles(Cfg.Dir, FlagSearchDir, pattern, Cfg.Exclude)
+	if err != nil {
+		return err
+	}
+
+	var idx *Index
+	if Cfg.IndexFile != "" {
+		if idx, err = loadIndex(true); err != nil {
+			Verbose("can not load the index, rank snippets without it. err: ", err)
+			idx = nil
+		}
+	}
+
+	results := rankSearchMatches(Cfg.Dir, matches, args[0], idx)
+	out, isFile := c.OutOrStdout().(*os.File)
+	return printOutput(c.OutOrStdout(), results, func(w io.Writer) error {
+		return printSearchResults(w, results, isFile && IsTerminal(out))
+	})
+}
+
+func CmdUse(c *cobra.Command, args []string) error {
+	values, err := parseVars(FlagUseVars)
+	if err != nil {
+		return err
+	}
+
+	body, err := readSnippetTemplate(args[0])
+	if err != nil {
+		return err
+	}
+
+	// Prompts go to stderr, so the rendered snippet can be piped to other commands.
+	placeholders := parsePlaceholders(body)
+	if err := promptPlaceholders(bufio.NewReader(c.InOrStdin()), c.ErrOrStderr(), placeholders, values); err != nil {
+		return err
+	}
+
+	text := unescapePlaceholders(renderPlaceholders(body, values))
+	return printOutput(c.OutOrStdout(), UseDoc{Name: args[0], Variables: values, Text: text}, func(w io.Writer) error {
+		_, err := io.WriteString(w, text)
+		return err
+	})
+}
+
+func CmdStatus(c *cobra.Command, _ []string) error {
+	status, err := readSyncStatus()
+	if err != nil {
+		return err
+	}
+
+	doc := StatusDoc(status)
+	return printOutput(c.OutOrStdout(), doc, func(w io.Writer) error {
+		if FlagStatusShort {
+			if short := status.short(); short != "" {
+				_, err := fmt.Fprintln(w, short)
+				return err
+			}
+			return nil
+		}
+
+		upstream := "no upstream branch"
+		if status.Upstream != "" {
+			upstream = fmt.Sprintf("ahead %d, behind %d of %s", status.Ahead, status.Behind, status.Upstream)
+		}
+		if _, err := fmt.Fprintf(w, "On branch %s (%s)\n", DefaultStr(status.Branch, "-"), upstream); err != nil {
+			return err
+		}
+
+		synced := true
+		for _, group := range []struct {
+			title string
+			names []string
+		}{
+			{"Conflicted", status.Conflicted}, {"New", status.New}, {"Modified", status.Modified}, {"Deleted", status.Deleted},
+		} {
+			if len(group.names) == 0 {
+				continue
+			}
+			synced = false
+			if _, err := fmt.Fprintf(w, "\n%s snippets:\n", group.title); err != nil {
+				return err
+			}
+			for _, name := range group.names {
+				if _, err := fmt.Fprintf(w, "  %s\n", name); err != nil {
+					return err
+				}
+			}
+		}
+
+		if synced && status.Ahead == 0 && status.Behind == 0 {
+			_, err := fmt.Fprintln(w, "\nNothing to sync")
+			return err
+		}
+		return nil
+	})
+}
+
+func CmdSync(c *cobra.Command, args []string) error {
+	if FlagSyncAbort {
+		if len(args) != 0 || slices.ContainsFunc([]string{"remote", "branch", "strategy", "autostash"}, c.Flags().Changed) {
+			return invalidArgument("the --abort flag can not be used with a message or other sync flags")
+		}
+		c.SilenceUsage = true // Arguments are valid, so the usage doesn't help with other errors.
+		if err := abortSync(); err != nil {
+			return err
+		}
+		return printOutput(os.Stdout, SyncDoc{Aborted: true}, func(w io.Writer) error {
+			_, err := fmt.Fprintln(w, "Restored the state before the sync")
+			return err
+		})
+	}
+
+	// The message argument wins over the message template.
+	doc := SyncDoc{}
+	var msgTmpl *template.Template
+	if len(args) > 0 {
+		doc.Message = args[0]
+	} else {
+		tmpl, err := parseSyncMessage(DefaultStr(Cfg.SyncMessage, defaultSyncMessage))
+		if err != nil {
+			return err
+		}
+		msgTmpl = tmpl
+	}
+
+	// While rebasing HEAD is detached, so an interrupted pull continues by the options of its sync.
+	resuming := pullInProgress() != ""
+	opts, err := resolveSyncOptions()
+	if err != nil && !resuming {
+		return err
+	}
+	c.SilenceUsage = true // Arguments are valid, so the usage doesn't help with git errors or quitting the sync.
+
+	state, err := beginSync(opts)
+	if err != nil {
+		return err
+	}
+	opts = state.Options
+
+	progress := progressWriter()
+	pull := func() error {
+		fmt.Fprintln(progress, "pull new changes")
+		if err := gitCommand(opts.pullArgs()...).Run(); err != nil && pullInProgress() == "" {
+			if !doc.Committed { // Nothing is changed, so there is nothing to abort.
+				return errors.Join(err, removeSyncState())
+			}
+			return err
+		}
+		return nil
+	}
+
+	if !resuming {
+		// A fast-forward pull can't have conflicts, but it refuses to pull when we have local commits, so we pull
+		// first and the pulled commits aren't a part of the sync to abort.
+		if opts.Strategy == SyncFFOnly {
+			if err := pull(); err != nil {
+				return err
+			}
+			state.Head, _ = gitOutput("rev-parse", "-q", "--verify", "HEAD")
+			if err := saveSyncState(state); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintln(progress, "Add new changes to the git index")
+		if err := gitCommand("add", "-A").Run(); err != nil {
+			return err
+		}
+
+		// We commit local changes before merging or rebasing, so conflicts are regular conflicts which we can resolve.
+		var exitErr *exec.ExitError
+		if err := gitCommand("diff", "HEAD", "--quiet").Run(); err != nil && (!errors.As(err, &exitErr) || exitErr.ExitCode() != 1) {
+			return err
+		} else if err != nil {
+			if msgTmpl != nil {
+				if doc.Message, err = syncMessage(msgTmpl); err != nil {
+					return err
+				}
+			}
+			fmt.Fprintln(progress, "commit new changes")
+			if err := gitCommand("commit", "-m", doc.Message).Run(); err != nil {
+				return err
+			}
+			doc.Committed = true
+			if state.Commit, err = gitOutput("rev-parse", "HEAD"); err != nil {
+				return err
+			}
+			if err := saveSyncState(state); err != nil {
+				return err
+			}
+		}
+
+		if opts.Strategy != SyncFFOnly {
+			if err := pull(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for pullInProgress() != "" {
+		files, err := conflictedFiles()
+		if err != nil {
+			return err
+		}
+		if len(files) != 0 {
+			fmt.Fprintln(progress, "Resolve conflicts")
+			if err := resolveConflicts(bufio.NewReader(c.InOrStdin()), c.ErrOrStderr(), files, &state); err != nil {
+				return err
+			}
+			doc.Conflicts += len(files)
+		}
+
+		fmt.Fprintln(progress, "continue the "+pullInProgress())
+		if err := continuePull(); err != nil {
+			// A rebase stops again on the next commit which has conflicts.
+			if files, _ := conflictedFiles(); len(files) == 0 {
+				return err
+			}
+		}
+		doc.Committed = true
+	}
+
+	if !doc.Committed {
+		fmt.Fprintln(progress, "You don't have any changes since last push")
+		return errors.Join(removeSyncState(), printOutput(os.Stdout, doc, noText))
+	}
+
+	fmt.Fprintln(progress, "Push changes")
+	if err := gitCommand(opts.pushArgs()...).Run(); err != nil {
+		return err
+	}
+	doc.Pushed = true
+
+	if err := removeSyncState(); err != nil {
+		return err
+	}
+	return printOutput(os.Stdout, doc, noText)
+}
+
+func CmdPrintVersion(*cobra.Command, []string) error {
+	doc := VersionDoc{
+		Version: DefaultStr(Version, "unknown"),
+		Commit:  DefaultStr(Commit, "unknown"),
+		Date:    DefaultStr(Date, "unknown"),
+	}
+
+	return printOutput(os.Stdout, doc, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "Version:  %s\nCommit:  %s\nBuild Date:  %s\n", doc.Version, doc.Commit, doc.Date)
+		return err
+	})
 }
@@ -1,13 +1,55 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/spf13/cobra"
 )
 
+func TestNewRootCmd(t *testing.T) {
+	defer resetConfig()
+	dir := t.TempDir()
+	setEnv(t, prefix+"_DIR", dir)
+	setEnv(t, "XDG_CONFIG_HOME", t.TempDir())
+
+	// Conflicting flags panic when they're merged with the persistent flags of the parents.
+	root := newRootCmd()
+	var walk func(c *cobra.Command) int
+	walk = func(c *cobra.Command) int {
+		assertTrue(t, c.InheritedFlags() != nil && c.LocalFlags() != nil)
+		count := 1
+		for _, child := range c.Commands() {
+			count += walk(child)
+		}
+		return count
+	}
+	assertTrue(t, walk(root) > 30)
+
+	for _, args := range [][]string{{"sync"}, {"config", "set"}, {"profile", "add"}, {"trash", "restore"}} {
+		c, _, err := root.Find(args)
+		assertEqual(t, err, nil)
+		assertEqual(t, c.Name(), args[len(args)-1])
+	}
+
+	// rm needs exactly one snippet.
+	root.SetArgs([]string{"rm"})
+	root.SetErr(io.Discard)
+	assertTrue(t, root.Execute() != nil)
+
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetArgs([]string{"config", "get", "dir"})
+	assertEqual(t, root.Execute(), nil)
+	assertEqual(t, out.String(), dir+"\n")
+}
+
 func TestBoot(t *testing.T) {
 	defer resetConfig()
 
@@ -17,6 +59,26 @@ func TestBoot(t *testing.T) {
 	assertEqual(t, Cfg.Dir, "/a/b/c")
 }
 
+func TestBootProfile(t *testing.T) {
+	defer resetConfig()
+
+	cmd := &cobra.Command{Use: "snip"}
+	setEnv(t, "XDG_CONFIG_HOME", t.TempDir())
+	setEnv(t, prefix+"_PROFILE", "work")
+	setEnv(t, "TASKS_DIR", "/tasks")
+	FlagProfile = "tasks"
+	defer func() {
+		FlagProfile = "" // Reset it.
+	}()
+	assertEqual(t, boot(cmd, nil), nil)
+	assertEqual(t, Cfg.Profile, "tasks")
+	assertEqual(t, Cfg.Dir, "/tasks")
+
+	resetConfig()
+	FlagProfile = "Tasks"
+	assertTrue(t, boot(cmd, nil) != nil)
+}
+
 func TestBootAndShutdown(t *testing.T) {
 	defer resetConfig()
 	f, err := os.CreateTemp("", "abc")
@@ -47,7 +109,7 @@ func TestCobraAutocompleteFilename(t *testing.T) {
 	defer resetConfig()
 
 	searchDir := t.TempDir()
-	Cfg = &Config{Dir: searchDir, Exclude: []string{".git"}}
+	Cfg = &Config{Dir: searchDir, Exclude: []string{".git", "abc/def/cart.yaml"}}
 
 	paths := []string{
 		"check.txt",
@@ -102,13 +164,17 @@ func TestCobraAutocompleteFilename(t *testing.T) {
 		},
 		{
 			tag:        "t7",
-			toComplete: "abc/def/ca",
-			res:        []string{"abc/def/cart.yaml"},
+			toComplete: "check2/b/ca",
+			res:        []string{"check2/b/cart.yaml"},
 		},
 		{
 			tag:        "t8",
 			toComplete: "abc/def/check",
 		},
+		{
+			tag:        "t9", // Paths are excluded in sub-directories too.
+			toComplete: "abc/def/ca",
+		},
 	}
 
 	// Search
@@ -149,8 +215,8 @@ func TestCmdSnippetsDir(t *testing.T) {
 func TestCmdEditSnippet(t *testing.T) {
 	tmpDir := t.TempDir()
 	Cfg = &Config{
-		Dir:    tmpDir,
-		Editor: "touch",
+		Dir:       tmpDir,
+		EditorCMD: []string{"touch"},
 	}
 
 	assertEqual(t, CmdEditSnippet(nil, []string{"a.md"}), nil)
@@ -169,6 +235,11 @@ func TestCmdEditSnippet(t *testing.T) {
 	_, err = os.Stat(path.Join(tmpDir, "c.yaml"))
 	assertEqual(t, err, nil)
 
+	// Open at a location
+	assertEqual(t, CmdEditSnippet(nil, []string{"d:42:5"}), nil)
+	_, err = os.Stat(path.Join(tmpDir, "d.md"))
+	assertEqual(t, err, nil)
+
 	// Open snippets dir
 	Cfg.Dir = path.Join(tmpDir, "a.yaml")
 	assertEqual(t, CmdEditSnippet(nil, nil), nil)
@@ -204,9 +275,725 @@ func TestCmdRemoveSnippet(t *testing.T) {
 
 	FlagRecursiveRemove = true
 	defer func() {
-		FlagRecursiveRemove = false // Reset it.
+		FlagRecursiveRemove, FlagRemoveYes = false, false // Reset it.
 	}()
-	assertEqual(t, CmdRemoveSnippet(nil, []string{"/check/"}), nil)
+
+	// Recursive removal needs confirmation.
+	cmd := &cobra.Command{}
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetIn(strings.NewReader("n\n"))
+	assertEqual(t, CmdRemoveSnippet(cmd, []string{"/check/"}), nil)
+	assertExists(t, tmpDir, "check/b.md")
+	cmd.SetIn(strings.NewReader("\n")) // The default answer is no.
+	assertEqual(t, CmdRemoveSnippet(cmd, []string{"/check/"}), nil)
+	assertExists(t, tmpDir, "check/b.md")
+
+	cmd.SetIn(strings.NewReader("y\n"))
+	assertEqual(t, CmdRemoveSnippet(cmd, []string{"/check/"}), nil)
 	_, err := os.Stat(path.Join(tmpDir, "check/"))
 	assertTrue(t, os.IsNotExist(err))
+
+	makeTree(t, tmpDir, "/check/a.md")
+	FlagRemoveYes = true
+	assertEqual(t, CmdRemoveSnippet(nil, []string{"/check/"}), nil)
+	_, err = os.Stat(path.Join(tmpDir, "check/"))
+	assertTrue(t, os.IsNotExist(err))
+
+	assertTrue(t, CmdRemoveSnippet(nil, []string{"/"}) != nil)
+}
+
+func TestCmdRemoveSnippetToTrash(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir, TrashDir: t.TempDir()}
+	makeTree(t, tmpDir, "a.md", "k8s/b.md", "k8s/c.md")
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+
+	assertEqual(t, CmdRemoveSnippet(cmd, []string{"a"}), nil)
+	FlagRecursiveRemove, FlagRemoveYes = true, true
+	defer func() {
+		FlagRecursiveRemove, FlagRemoveYes, FlagForceRestore, FlagTrashOlderThan = false, false, false, "" // Reset it.
+	}()
+	assertEqual(t, CmdRemoveSnippet(cmd, []string{"k8s/"}), nil)
+
+	items, err := listTrash()
+	assertEqual(t, err, nil)
+	assertEqual(t, len(items), 2)
+
+	assertEqual(t, CmdTrashList(cmd, nil), nil)
+	assertTrue(t, strings.Contains(out.String(), items[0].ID))
+
+	// Restore
+	makeTree(t, tmpDir, "a.md")
+	var aItem trashItem
+	for _, item := range items {
+		if item.Path == "a.md" {
+			aItem = item
+		}
+	}
+	assertTrue(t, CmdTrashRestore(cmd, []string{aItem.ID}) != nil)
+	FlagForceRestore = true
+	assertEqual(t, CmdTrashRestore(cmd, []string{aItem.ID}), nil)
+	b, _ := os.ReadFile(path.Join(tmpDir, "a.md"))
+	assertEqual(t, string(b), fmt.Sprintf("The %s file", path.Join(tmpDir, "a.md")))
+	assertTrue(t, CmdTrashRestore(cmd, []string{aItem.ID}) != nil)
+
+	FlagTrashOlderThan = "30d"
+	assertEqual(t, CmdTrashEmpty(cmd, nil), nil)
+	items, _ = listTrash()
+	assertEqual(t, len(items), 2) // The overwritten a.md is in the trash too.
+
+	FlagTrashOlderThan = ""
+	assertEqual(t, CmdTrashEmpty(cmd, nil), nil)
+	items, _ = listTrash()
+	assertEqual(t, len(items), 0)
+
+	FlagTrashOlderThan = "abc"
+	assertTrue(t, CmdTrashEmpty(cmd, nil) != nil)
+}
+
+func TestCmdSearch(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir}
+	makeTree(t, tmpDir, "a.md", "b/c.yaml")
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "min.js"), []byte(strings.Repeat("x", maxSearchLine+10)+"\nfile\n"), 0644), nil)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	assertEqual(t, CmdSearch(cmd, []string{"c.yaml"}), nil)
+	assertEqual(t, out.String(), fmt.Sprintf("b/c.yaml:1: The %s file\n", path.Join(tmpDir, "b/c.yaml")))
+
+	// A long line doesn't fail the search.
+	FlagSearchRegex = true
+	out.Reset()
+	assertEqual(t, CmdSearch(cmd, []string{"^file$"}), nil)
+	assertEqual(t, out.String(), "min.js:2: file\n")
+
+	// The snippets dir may be a symlink.
+	link := path.Join(t.TempDir(), "link")
+	assertEqual(t, os.Symlink(tmpDir, link), nil)
+	Cfg.Dir = link
+	out.Reset()
+	assertEqual(t, CmdSearch(cmd, []string{"^file$"}), nil)
+	assertEqual(t, out.String(), "min.js:2: file\n")
+
+	FlagOutput = OutputJSON
+	defer func() {
+		FlagSearchRegex, FlagOutput = false, OutputText // Reset it.
+	}()
+	out.Reset()
+	assertEqual(t, CmdSearch(cmd, []string{"fi.e"}), nil)
+	assertTrue(t, strings.Contains(out.String(), `"path": "a"`))
+	assertTrue(t, CmdSearch(cmd, []string{"a("}) != nil)
+}
+
+func TestCmdIndex(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir, IndexFile: path.Join(t.TempDir(), "snip.index.json")}
+	makeTree(t, tmpDir, "a.md", "b/c.yaml")
+
+	assertEqual(t, CmdIndex(nil, nil), nil)
+	assertExists(t, path.Dir(Cfg.IndexFile), "snip.index.json")
+
+	FlagRebuildIndex = true
+	defer func() {
+		FlagRebuildIndex = false // Reset it.
+	}()
+	assertEqual(t, CmdIndex(nil, nil), nil)
+
+	Cfg.IndexFile = ""
+	assertTrue(t, CmdIndex(nil, nil) != nil)
+}
+
+func TestCmdInit(t *testing.T) {
+	dir := t.TempDir()
+	initGitRepo(t, dir) // Sets the git identity.
+	runGit(t, dir, "init", "-q", "--bare", "remote")
+	Cfg = &Config{Dir: path.Join(dir, "snippets"), Git: "git"}
+
+	FlagInitRemote = path.Join(dir, "remote")
+	defer func() {
+		FlagInitRemote = "" // Reset it.
+	}()
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	assertEqual(t, CmdInit(cmd, nil), nil)
+	assertTrue(t, strings.HasPrefix(out.String(), "Initialized the snippets repository in "+Cfg.Dir+", and pushed"))
+	assertTrue(t, errors.Is(CmdInit(cmd, nil), os.ErrExist))
+}
+
+func TestCmdViewSnippetFrontMatter(t *testing.T) {
+	tmpDir := t.TempDir()
+	out := path.Join(t.TempDir(), "out")
+	Cfg = &Config{
+		Dir:               tmpDir,
+		MarkdownViewerCMD: []string{"sh", "-c", "cat $0 > " + out},
+	}
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "a.md"), []byte("---\ndescription: abc\n---\nbody"), 0644), nil)
+
+	assertEqual(t, CmdViewSnippet(nil, []string{"a"}), nil)
+	b, err := os.ReadFile(out)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(b), "body")
+
+	FlagShowFrontMatter = true
+	defer func() {
+		FlagShowFrontMatter = false // Reset it.
+	}()
+	assertEqual(t, CmdViewSnippet(nil, []string{"a"}), nil)
+	b, err = os.ReadFile(out)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(b), "---\ndescription: abc\n---\nbody")
+}
+
+func TestCmdListAndTags(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir}
+	makeTree(t, tmpDir, "a.md", "b/c.md", "d.md")
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "a.md"), []byte("#k8s #debug"), 0644), nil)
+	// Its front-matter is invalid, so just its hashtags are read.
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "d.md"), []byte("---\ntags: [a\n---\n#k8s"), 0644), nil)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	assertEqual(t, CmdList(cmd, nil), nil)
+	assertEqual(t, out.String(), "a\nb/c\nd\n")
+	out.Reset()
+	assertEqual(t, CmdList(cmd, []string{"b"}), nil)
+	assertEqual(t, out.String(), "b/c\n")
+	assertTrue(t, CmdList(cmd, []string{"x"}) != nil)
+
+	for _, indexFile := range []string{"", path.Join(t.TempDir(), "index.json")} {
+		Cfg.IndexFile = indexFile
+		out.Reset()
+		assertEqual(t, CmdTags(cmd, nil), nil)
+		assertEqual(t, out.String(), "k8s    2\ndebug  1\n")
+	}
+
+	FlagListTags = []string{"k8s", "debug"}
+	defer func() {
+		FlagListTags = nil // Reset it.
+	}()
+	out.Reset()
+	assertEqual(t, CmdList(cmd, nil), nil)
+	assertEqual(t, out.String(), "a\n")
+
+	res, _ := cobraAutoCompleteTag(nil, nil, "")
+	assertEqualSlice(t, res, []string{"k8s", "debug"})
+}
+
+func TestCmdListModes(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir, Git: "git"}
+	makeTree(t, tmpDir, "a.md", "b/c.md")
+	cmd := &cobra.Command{}
+	cmd.SetOut(&bytes.Buffer{})
+
+	defer func() { // Reset flags
+		FlagListTree, FlagListLong, FlagListSort, FlagListDepth = false, false, "name", 0
+	}()
+
+	FlagListTree = true
+	assertEqual(t, CmdList(cmd, nil), nil)
+	FlagListTree, FlagListLong, FlagListSort, FlagListDepth = false, true, "mtime", 1
+	assertEqual(t, CmdList(cmd, nil), nil)
+	FlagListSort = "abc"
+	assertTrue(t, CmdList(cmd, nil) != nil)
+}
+
+func TestStructuredOutput(t *testing.T) {
+	defer resetConfig()
+	tmpDir := t.TempDir()
+	setEnv(t, prefix+"_DIR", tmpDir)
+	setEnv(t, prefix+"_INDEX_FILE", path.Join(t.TempDir(), "index.json"))
+	makeTree(t, tmpDir, "a.md")
+
+	defer func() {
+		FlagOutput = OutputText // Reset it.
+	}()
+
+	FlagOutput = "abc"
+	assertTrue(t, boot(&cobra.Command{}, nil) != nil)
+
+	FlagOutput = OutputYAML
+	cmd := &cobra.Command{}
+	assertEqual(t, boot(cmd, nil), nil)
+	assertTrue(t, cmd.SilenceErrors && cmd.SilenceUsage)
+
+	assertEqual(t, CmdViewSnippet(nil, []string{"a"}), nil)
+	assertTrue(t, CmdViewSnippet(nil, []string{"b"}) != nil)
+	assertEqual(t, CmdSnippetsDir(nil, nil), nil)
+	assertEqual(t, CmdPrintVersion(nil, nil), nil)
+	assertEqual(t, CmdCompletionGenerator(cmd, []string{"zsh"}), nil)
+	assertEqual(t, CmdList(cmd, nil), nil)
+	assertEqual(t, CmdTags(cmd, nil), nil)
+}
+
+func TestCmdUse(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir}
+	contents := "---\ntitle: logs {{title}}\n---\nkubectl logs {{pod}} -n {{ns:default}}\n"
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "a.md"), []byte(contents), 0644), nil)
+
+	// Placeholders of the front-matter are not completed, like they're not prompted.
+	res, _ := cobraAutoCompletePlaceholder(nil, []string{"a"}, "")
+	assertEqualSlice(t, res, []string{"pod=", "ns=default"})
+
+	var out, prompts bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetIn(strings.NewReader("web\n"))
+	cmd.SetOut(&out)
+	cmd.SetErr(&prompts)
+
+	FlagUseVars = []string{"ns=prod"}
+	defer func() {
+		FlagUseVars = nil // Reset it.
+	}()
+	assertEqual(t, CmdUse(cmd, []string{"a"}), nil)
+	assertEqual(t, out.String(), "kubectl logs web -n prod\n")
+	assertEqual(t, prompts.String(), "pod: ")
+
+	FlagUseVars = []string{"bad"}
+	assertTrue(t, CmdUse(cmd, []string{"a"}) != nil)
+	FlagUseVars = nil
+	assertTrue(t, CmdUse(cmd, []string{"not-found"}) != nil)
+}
+
+func TestCmdRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	out := path.Join(t.TempDir(), "out")
+	Cfg = &Config{Dir: tmpDir}
+	t.Setenv("SHELL", "sh")
+	contents := "# Deploy\n```bash\necho a >> " + out + "\n```\n```sh\nexit 3\n```\n"
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "a.md"), []byte(contents), 0644), nil)
+
+	var stdout, stderr bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+
+	// Dry run
+	FlagRunDryRun = true
+	defer func() {
+		FlagRunDryRun, FlagRunBlock, FlagRunYes = false, "", false // Reset it.
+	}()
+	assertEqual(t, CmdRun(cmd, []string{"a"}), nil)
+	assertEqual(t, stdout.String(), "echo a >> "+out+"\nexit 3\n")
+	FlagRunDryRun = false
+
+	// Reject running it.
+	FlagRunBlock = "1"
+	cmd.SetIn(strings.NewReader("n\n"))
+	assertEqual(t, CmdRun(cmd, []string{"a"}), nil)
+	assertTrue(t, strings.Contains(stderr.String(), "Running with sh:\n  echo a"))
+	_, err := os.Stat(out)
+	assertTrue(t, os.IsNotExist(err))
+
+	cmd.SetIn(strings.NewReader("y\n"))
+	assertEqual(t, CmdRun(cmd, []string{"a"}), nil)
+	assertExists(t, path.Dir(out), "out")
+
+	// The exit code must be propagated.
+	FlagRunBlock, FlagRunYes = "", true
+	err = CmdRun(cmd, []string{"a"})
+	assertEqual(t, exitCode(err), 3)
+	b, _ := os.ReadFile(out)
+	assertEqual(t, string(b), "a\na\n")
+
+	// Blocks are numbered like other commands, and the flag wins over the anchor of the name.
+	err = CmdRun(cmd, []string{"a#2"})
+	assertEqual(t, exitCode(err), 3)
+	FlagRunBlock = "bash"
+	assertEqual(t, CmdRun(cmd, []string{"a#2"}), nil)
+	b, _ = os.ReadFile(out)
+	assertEqual(t, string(b), "a\na\na\n")
+
+	FlagRunBlock = "5"
+	assertTrue(t, CmdRun(cmd, []string{"a"}) != nil)
+}
+
+func TestCmdViewSnippetBlock(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir}
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "a.md"), []byte(blockTestText), 0644), nil)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	assertEqual(t, CmdViewSnippet(cmd, []string{"a#2"}), nil)
+	assertEqual(t, out.String(), "git reset --soft HEAD~2\n")
+
+	out.Reset()
+	FlagViewBlock = "rebase"
+	defer func() {
+		FlagViewBlock = "" // Reset it.
+	}()
+	assertEqual(t, CmdViewSnippet(cmd, []string{"a"}), nil)
+	assertEqual(t, out.String(), "git rebase -i\n")
+
+	FlagViewBlock = "unknown"
+	assertTrue(t, CmdViewSnippet(cmd, []string{"a"}) != nil)
+
+	// Auto-completion of the anchors
+	res, _ := cobraAutoCompleteFileName(nil, nil, "a#r")
+	assertEqualSlice(t, res, []string{"a#rebase\tbash: git rebase -i"})
+	res, _ = cobraAutoCompleteBlockAnchor(nil, []string{"a"}, "3")
+	assertEqualSlice(t, res, []string{"3\tplain"})
+	res, _ = cobraAutoCompleteShellBlockAnchor(nil, []string{"a"}, "")
+	assertEqualSlice(t, res, []string{"1\tbash: git rebase -i", "rebase\tbash: git rebase -i", "2\tbash: git reset --soft HEAD~2",
+		"title=squash\tbash: git reset --soft HEAD~2", "squash\tbash: git reset --soft HEAD~2"})
+	res, _ = cobraAutoCompleteFileName(nil, nil, "a#s")
+	assertEqualSlice(t, res, []string{"a#squash\tbash: git reset --soft HEAD~2"})
+
+	// The anchor follows the last '#' of a snippet's name.
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "b#c.md"), []byte(blockTestText), 0644), nil)
+	res, _ = cobraAutoCompleteFileName(nil, nil, "b#c#3")
+	assertEqualSlice(t, res, []string{"b#c#3\tplain"})
+	res, _ = cobraAutoCompleteFileName(nil, nil, "b#")
+	assertEqualSlice(t, res, []string{"b#c"})
+}
+
+func TestCmdCopy(t *testing.T) {
+	t.Setenv("TMUX", "")
+	term := fakeTerminal(t, "")
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir, MarkdownViewerCMD: []string{"true"}}
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "a.md"), []byte(blockTestText), 0644), nil)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	assertEqual(t, CmdCopy(cmd, []string{"a#2"}), nil)
+	assertEqual(t, out.String(), "Copied a#2 to the clipboard\n")
+	assertEqual(t, term.String(), osc52Sequence("git reset --soft HEAD~2\n", false))
+	assertTrue(t, CmdCopy(cmd, []string{"b"}) != nil)
+
+	// The --copy flag of the root command copies the snippet and views it too.
+	term.Reset()
+	FlagCopy = true
+	defer func() {
+		FlagCopy = false // Reset it.
+	}()
+	assertEqual(t, CmdViewSnippet(cmd, []string{"a"}), nil)
+	assertEqual(t, term.String(), osc52Sequence(blockTestText, false))
+}
+
+func TestCmdAdd(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir}
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+
+	cmd.SetIn(strings.NewReader("ls -la"))
+	assertEqual(t, CmdAdd(cmd, []string{"shell/last"}), nil)
+	assertEqual(t, out.String(), "Saved: "+path.Join(tmpDir, "shell/last.md")+"\n")
+
+	FlagAddAppend, FlagAddTitle = true, "Disk usage"
+	defer func() {
+		FlagAddAppend, FlagAddForce, FlagAddTitle = false, false, "" // Reset it.
+	}()
+	cmd.SetIn(strings.NewReader("du -sh\n"))
+	assertEqual(t, CmdAdd(cmd, []string{"shell/last"}), nil)
+	b, _ := os.ReadFile(path.Join(tmpDir, "shell/last.md"))
+	assertEqual(t, string(b), "ls -la\n# Disk usage\n\ndu -sh\n")
+
+	// Overwrite it after confirmation.
+	FlagAddAppend, FlagAddTitle = false, ""
+	fakeTerminal(t, "n\n")
+	cmd.SetIn(strings.NewReader("pwd\n"))
+	assertEqual(t, CmdAdd(cmd, []string{"shell/last"}), nil)
+	b, _ = os.ReadFile(path.Join(tmpDir, "shell/last.md"))
+	assertEqual(t, string(b), "ls -la\n# Disk usage\n\ndu -sh\n")
+
+	FlagAddForce = true
+	cmd.SetIn(strings.NewReader("pwd\n"))
+	assertEqual(t, CmdAdd(cmd, []string{"shell/last"}), nil)
+	b, _ = os.ReadFile(path.Join(tmpDir, "shell/last.md"))
+	assertEqual(t, string(b), "pwd\n")
+
+	cmd.SetIn(strings.NewReader(" \n"))
+	assertTrue(t, CmdAdd(cmd, []string{"shell/empty"}) != nil)
+	assertTrue(t, CmdAdd(cmd, []string{"shell/"}) != nil)
+}
+
+func TestCmdMove(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir, Git: "git"}
+	makeTree(t, tmpDir, "k8s/a.md", "k8s/b.md", "c.md")
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "index.md"), []byte("[a](k8s/a.md) [c](c.md)"), 0644), nil)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+
+	assertEqual(t, CmdMove(cmd, []string{"c", "docs/"}), nil)
+	assertExists(t, tmpDir, "docs/c.md")
+	assertEqual(t, out.String(), "Moved: c.md -> docs/c.md\nUpdated links: index.md\n")
+
+	assertEqual(t, CmdMove(cmd, []string{"k8s/", "ops/k8s"}), nil)
+	assertExists(t, tmpDir, "ops/k8s/a.md", "ops/k8s/b.md")
+	b, _ := os.ReadFile(path.Join(tmpDir, "index.md"))
+	assertEqual(t, string(b), "[a](ops/k8s/a.md) [c](docs/c.md)")
+
+	// Refuse to overwrite
+	assertTrue(t, CmdMove(cmd, []string{"ops/k8s/a", "ops/k8s/b"}) != nil)
+	FlagMoveForce = true
+	defer func() {
+		FlagMoveForce = false // Reset it.
+	}()
+	assertEqual(t, CmdMove(cmd, []string{"ops/k8s/a", "ops/k8s/b"}), nil)
+
+	assertTrue(t, CmdMove(cmd, []string{"ops/", "ops/k8s/"}) != nil)
+	assertTrue(t, CmdMove(cmd, []string{"not-found", "x"}) != nil)
+	assertTrue(t, CmdMove(cmd, []string{"index", "../x"}) != nil)
+}
+
+func TestCmdCp(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir}
+	makeTree(t, tmpDir, "svc/a.md", "b.md")
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "deploy.md"), []byte("deploy {{service}}"), 0644), nil)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+
+	FlagCopyVars = []string{"service=billing"}
+	defer func() {
+		FlagCopyVars, FlagRecursiveCopy, FlagForceCopy = nil, false, false // Reset it.
+	}()
+	assertEqual(t, CmdCp(cmd, []string{"deploy", "billing/"}), nil)
+	assertEqual(t, out.String(), "Copied: deploy.md -> billing/deploy.md\n")
+	b, _ := os.ReadFile(path.Join(tmpDir, "billing/deploy.md"))
+	assertEqual(t, string(b), "deploy billing")
+
+	FlagCopyVars = nil
+	assertTrue(t, CmdCp(cmd, []string{"deploy", "b"}) != nil) // Exists
+	assertTrue(t, CmdCp(cmd, []string{"svc/", "svc2/"}) != nil)
+
+	FlagRecursiveCopy, FlagForceCopy = true, true
+	assertEqual(t, CmdCp(cmd, []string{"svc/", "svc2/"}), nil)
+	assertExists(t, tmpDir, "svc/a.md", "svc2/a.md")
+	assertEqual(t, CmdCp(cmd, []string{"deploy", "b"}), nil)
+	b, _ = os.ReadFile(path.Join(tmpDir, "b.md"))
+	assertEqual(t, string(b), "deploy {{service}}")
+
+	assertTrue(t, CmdCp(cmd, []string{"svc/", "svc/x/"}) != nil)
+	FlagCopyVars = []string{"bad"}
+	assertTrue(t, CmdCp(cmd, []string{"deploy", "c"}) != nil)
+}
+
+func TestCmdConfig(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "config.toml")
+	Cfg = &Config{ConfigFile: fpath, EditorCMD: []string{"touch"}, Profile: "tasks", settings: []configSetting{
+		{Key: "dir", Value: "/a", Source: "default"},
+		{Key: "editor", Value: "vi", Source: "env SNIP_EDITOR"},
+	}}
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+
+	assertEqual(t, CmdConfigList(cmd, nil), nil)
+	assertEqual(t, out.String(), "KEY     VALUE  SOURCE\ndir     /a     default\neditor  vi     env SNIP_EDITOR\n\nConfig file: "+fpath+"\n")
+
+	out.Reset()
+	assertEqual(t, CmdConfigGet(cmd, []string{"editor"}), nil)
+	assertEqual(t, out.String(), "vi\n")
+	assertTrue(t, CmdConfigGet(cmd, []string{"abc"}) != nil)
+
+	out.Reset()
+	assertEqual(t, CmdConfigSet(cmd, []string{"editor", "nvim"}), nil)
+	assertEqual(t, out.String(), "Set editor in "+fpath+"\nNote: the SNIP_EDITOR env variable overrides it\n")
+	FlagConfigGlobal = true
+	defer func() {
+		FlagConfigGlobal = false // Reset it.
+	}()
+	assertEqual(t, CmdConfigSet(cmd, []string{"dir", "/b"}), nil)
+	assertTrue(t, CmdConfigSet(cmd, []string{"abc", "/b"}) != nil)
+	b, _ := os.ReadFile(fpath)
+	assertEqual(t, string(b), "dir = \"/b\"\n\n[tasks]\neditor = \"nvim\"\n")
+
+	assertEqual(t, CmdConfigEdit(cmd, nil), nil)
+}
+
+func TestCmdProfile(t *testing.T) {
+	dir := t.TempDir()
+	Cfg = &Config{Dir: path.Join(dir, "snippets"), ConfigFile: path.Join(dir, "config.toml"), Profile: "snip", file: configFile{}}
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+
+	assertEqual(t, CmdProfileAdd(cmd, []string{"tasks", path.Join(dir, "tasks")}), nil)
+	assertEqual(t, out.String(), "Added profile tasks: "+path.Join(dir, "tasks")+"\n")
+	assertExists(t, dir, "tasks")
+	assertTrue(t, CmdProfileAdd(cmd, []string{"Tasks", dir}) != nil)
+	assertTrue(t, CmdProfileAdd(cmd, []string{"sh", dir}) != nil) // It'd shadow the sh command.
+
+	var err error
+	Cfg.file, err = readConfigFile(Cfg.ConfigFile, dir, true)
+	assertEqual(t, err, nil)
+	assertTrue(t, errors.Is(CmdProfileAdd(cmd, []string{"tasks", dir}), os.ErrExist))
+
+	out.Reset()
+	assertEqual(t, CmdProfileList(cmd, nil), nil)
+	assertEqual(t, out.String(), "* snip   "+Cfg.Dir+"\n  tasks  "+path.Join(dir, "tasks")+"\n")
+
+	out.Reset()
+	assertEqual(t, CmdProfileRemove(cmd, []string{"tasks"}), nil)
+	assertEqual(t, out.String(), "Removed profile tasks, its snippets are kept in "+path.Join(dir, "tasks")+"\n")
+	b, _ := os.ReadFile(Cfg.ConfigFile)
+	assertEqual(t, string(b), "")
+	assertTrue(t, errors.Is(CmdProfileRemove(cmd, []string{"snip"}), os.ErrNotExist))
+}
+
+func TestCmdDoctor(t *testing.T) {
+	dir := t.TempDir()
+	Cfg = &Config{Dir: dir, FileViewerCMD: []string{"cat"}, MarkdownViewerCMD: []string{"cat"}, EditorCMD: []string{"cat"}, Git: "git"}
+	setEnv(t, "SHELL", "/bin/tcsh")
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+
+	assertEqual(t, CmdDoctor(cmd, nil), nil)
+	assertTrue(t, strings.Contains(out.String(), "[warn] Git repo: the snippets dir is not a git repository"))
+	assertTrue(t, strings.HasSuffix(out.String(), "\n0 failed, 2 warning(s)\n"))
+
+	out.Reset()
+	Cfg.EditorCMD = []string{"not-found-editor"}
+	assertEqual(t, CmdDoctor(cmd, nil), errChecksFailed)
+	assertTrue(t, strings.Contains(out.String(), "[fail] Editor: not-found-editor is not found in PATH\n       Fix: "))
+	assertTrue(t, cmd.SilenceErrors && cmd.SilenceUsage)
+}
+
+func TestCmdStatus(t *testing.T) {
+	dir := t.TempDir()
+	Cfg = &Config{Dir: dir, Git: "git"}
+	initGitRepo(t, dir)
+	runGit(t, dir, "commit", "-q", "--allow-empty", "-m", "init")
+	runGit(t, dir, "branch", "-M", "main")
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+
+	assertEqual(t, CmdStatus(cmd, nil), nil)
+	assertEqual(t, out.String(), "On branch main (no upstream branch)\n\nNothing to sync\n")
+
+	makeTree(t, dir, "a.md", "b/c.md")
+	out.Reset()
+	assertEqual(t, CmdStatus(cmd, nil), nil)
+	assertEqual(t, out.String(), "On branch main (no upstream branch)\n\nNew snippets:\n  a\n  "+path.Join("b", "c")+"\n")
+
+	FlagStatusShort = true
+	defer func() {
+		FlagStatusShort = false // Reset it.
+	}()
+	out.Reset()
+	assertEqual(t, CmdStatus(cmd, nil), nil)
+	assertEqual(t, out.String(), "+2\n")
+}
+
+func TestCmdSync(t *testing.T) {
+	local, other := makeSyncRepos(t)
+	Cfg = &Config{Dir: local, Git: "git"}
+
+	// Invalid flags print the usage.
+	cmd := &cobra.Command{}
+	FlagSyncStrategy = "unknown"
+	assertTrue(t, CmdSync(cmd, nil) != nil)
+	assertTrue(t, !cmd.SilenceUsage)
+	FlagSyncStrategy = ""
+
+	cmd.SetIn(strings.NewReader("m\nt\n"))
+	cmd.SetErr(&bytes.Buffer{})
+	assertEqual(t, CmdSync(cmd, nil), nil)
+	assertTrue(t, cmd.SilenceUsage)
+
+	// The other clone gets the resolved changes.
+	runGit(t, other, "pull", "-q")
+	b, _ := os.ReadFile(path.Join(other, "a.md"))
+	assertEqual(t, string(b), "mine\n")
+	assertExists(t, other, "a.md")
+	_, err := os.Stat(path.Join(other, "x.md"))
+	assertTrue(t, errors.Is(err, os.ErrNotExist))
+	_, err = loadSyncState()
+	assertTrue(t, errors.Is(err, os.ErrNotExist))
+
+	assertEqual(t, runGit(t, local, "log", "-1", "--format=%s", "HEAD^1"), "snip: edit a, x\n")
+
+	// Nothing to sync
+	assertEqual(t, CmdSync(cmd, nil), nil)
+
+	// Abort
+	assertEqual(t, os.WriteFile(path.Join(other, "a.md"), []byte("other\n"), 0644), nil)
+	runGit(t, other, "commit", "-q", "-am", "other")
+	runGit(t, other, "push", "-q")
+	assertEqual(t, os.WriteFile(path.Join(local, "a.md"), []byte("local\n"), 0644), nil)
+	cmd.SetIn(strings.NewReader("q\n"))
+	assertEqual(t, CmdSync(cmd, nil), errSyncQuit)
+
+	FlagSyncAbort = true
+	defer func() {
+		FlagSyncAbort = false // Reset it.
+	}()
+	var argErr *argumentError
+	assertTrue(t, errors.As(CmdSync(cmd, []string{"msg"}), &argErr)) // It can't be used with a message.
+	assertEqual(t, CmdSync(cmd, nil), nil)
+	assertEqual(t, runGit(t, local, "status", "--porcelain"), " M a.md\n")
+}
+
+func TestCmdSyncRebase(t *testing.T) {
+	defer func() {
+		FlagSyncStrategy = "" // Reset it.
+	}()
+	local, other := makeSyncRepos(t)
+	Cfg = &Config{Dir: local, Git: "git"}
+	FlagSyncStrategy = SyncRebase
+
+	cmd := &cobra.Command{}
+	cmd.SetIn(strings.NewReader("m\nt\n"))
+	cmd.SetErr(&bytes.Buffer{})
+	assertEqual(t, CmdSync(cmd, nil), nil)
+	assertEqual(t, pullInProgress(), "")
+
+	// Mine and theirs are the same as merging, and the history is linear.
+	runGit(t, other, "pull", "-q")
+	b, _ := os.ReadFile(path.Join(other, "a.md"))
+	assertEqual(t, string(b), "mine\n")
+	_, err := os.Stat(path.Join(other, "x.md"))
+	assertTrue(t, errors.Is(err, os.ErrNotExist))
+	assertEqual(t, runGit(t, local, "rev-list", "--merges", "HEAD"), "")
+}
+
+func TestCmdSyncFFOnly(t *testing.T) {
+	defer func() {
+		FlagSyncStrategy = "" // Reset it.
+	}()
+	local, other := makeSyncRepos(t)
+	Cfg = &Config{Dir: local, Git: "git"}
+	FlagSyncStrategy = SyncFFOnly
+	cmd := &cobra.Command{}
+
+	// The local changes conflict with the pulled ones.
+	assertTrue(t, CmdSync(cmd, nil) != nil)
+	_, err := loadSyncState()
+	assertTrue(t, errors.Is(err, os.ErrNotExist))
+
+	runGit(t, local, "checkout", "-q", "--", ".")
+	assertEqual(t, os.WriteFile(path.Join(local, "b.md"), []byte("b\n"), 0644), nil)
+	assertEqual(t, CmdSync(cmd, nil), nil)
+	runGit(t, other, "pull", "-q")
+	assertExists(t, other, "b.md")
+	assertEqual(t, runGit(t, local, "rev-list", "--merges", "HEAD"), "")
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// isMarkdown reports whether the file is a markdown file.
+func isMarkdown(fname string) bool {
+	return strings.HasSuffix(fname, ".md")
+}
+
+// isFence reports whether the line opens or closes a fenced code block.
+func isFence(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")
+}
+
+// markdownHeadings returns ATX headings (e.g., "## title") of a markdown text. It ignores lines in code blocks.
+func markdownHeadings(text string) []string {
+	var res []string
+	inCode := false
+	for _, line := range strings.Split(text, "\n") {
+		if isFence(line) {
+			inCode = !inCode
+			continue
+		}
+
+		if inCode {
+			continue
+		}
+
+		if heading, ok := parseHeading(line); ok {
+			res = append(res, heading)
+		}
+	}
+	return res
+}
+
+// parseHeading returns text of an ATX heading line.
+func parseHeading(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 || (len(line) > level && line[level] != ' ' && line[level] != '\t') {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimRight(line[level:], "#")), true
+}
+
+// codeBlock is a fenced code block of a markdown text.
+type codeBlock struct {
+	Info    string // The info string of the opening fence (e.g., "bash title=deploy").
+	Heading string // The nearest heading before the code block.
+	Code    string
+	Line    int // Line number of the opening fence.
+}
+
+// Lang returns the language of the code block which is the first word of its info string.
+func (b codeBlock) Lang() string {
+	lang, _, _ := strings.Cut(b.Info, " ")
+	return strings.ToLower(lang)
+}
+
+// markdownCodeBlocks returns fenced code blocks of a markdown text. An unclosed code block continues to
+// the end of the text.
+func markdownCodeBlocks(text string) []codeBlock {
+	var res []codeBlock
+	var current *codeBlock
+	var lines []string
+	heading := ""
+	for i, line := range strings.Split(text, "\n") {
+		if !isFence(line) {
+			if current != nil {
+				lines = append(lines, line)
+			} else if h, ok := parseHeading(line); ok {
+				heading = h
+			}
+			continue
+		}
+
+		if current == nil {
+			info := strings.TrimLeft(strings.TrimSpace(line), "`~")
+			current, lines = &codeBlock{Info: strings.TrimSpace(info), Heading: heading, Line: i + 1}, nil
+			continue
+		}
+
+		if len(lines) != 0 {
+			current.Code = strings.Join(lines, "\n") + "\n"
+		}
+		res, current = append(res, *current), nil
+	}
+
+	if current != nil {
+		current.Code = strings.Join(lines, "\n")
+		res = append(res, *current)
+	}
+	return res
+}
+
+// headingSlug returns the anchor of a heading like "Squash commits" -> "squash-commits".
+func headingSlug(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestMarkdownHeadings(t *testing.T) {
+	text := "# Title\ntext #tag\n## Sub title ##\n```\n# comment\n```\n####### not heading\n#nospace\n###\n"
+	assertEqualSlice(t, markdownHeadings(text), []string{"Title", "Sub title", ""})
+}
+
+func TestMarkdownCodeBlocks(t *testing.T) {
+	text := "# Title\n```bash title=x\necho a\necho b\n```\ntext\n~~~\nplain\n~~~\n```Sh\nunclosed"
+	blocks := markdownCodeBlocks(text)
+
+	assertEqual(t, len(blocks), 3)
+	assertEqual(t, blocks[0].Info, "bash title=x")
+	assertEqual(t, blocks[0].Lang(), "bash")
+	assertEqual(t, blocks[0].Code, "echo a\necho b\n")
+	assertEqual(t, blocks[0].Line, 2)
+	assertEqual(t, blocks[0].Heading, "Title")
+	assertEqual(t, blocks[1].Lang(), "")
+	assertEqual(t, blocks[1].Code, "plain\n")
+	assertEqual(t, blocks[2].Lang(), "sh")
+	assertEqual(t, blocks[2].Code, "unclosed")
+}
+
+func TestHeadingSlug(t *testing.T) {
+	assertEqual(t, headingSlug(" Squash the Commits! "), "squash-the-commits")
+	assertEqual(t, headingSlug("git_rebase (v2.0)"), "git_rebase-v20")
+	assertEqual(t, headingSlug(""), "")
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var FlagMoveForce = false
+
+var (
+	// mdLinkRegex matches inline markdown links and images like [text](target "title").
+	mdLinkRegex = regexp.MustCompile(`(!?\[[^\]]*\]\()([^)\s]+)(\s+"[^"]*")?\)`)
+	// wikiLinkRegex matches wiki-links like [[name]], [[name#heading]] and [[name|alias]].
+	wikiLinkRegex = regexp.MustCompile(`\[\[([^\]|#]+)([#|][^\]]*)?\]\]`)
+)
+
+// snippetMove is a move of a snippet or a directory of snippets. Keys are slash-separated paths relative to
+// the snippets dir.
+type snippetMove struct {
+	From  string
+	To    string
+	IsDir bool
+}
+
+// apply returns the key after the move. It returns false if the move doesn't affect the key.
+func (m snippetMove) apply(key string) (string, bool) {
+	if key == m.From {
+		return m.To, true
+	}
+	if m.IsDir && strings.HasPrefix(key, m.From+"/") {
+		return m.To + strings.TrimPrefix(key, m.From), true
+	}
+	return key, false
+}
+
+// revert returns the key before the move.
+func (m snippetMove) revert(key string) string {
+	res, _ := snippetMove{From: m.To, To: m.From, IsDir: m.IsDir}.apply(key)
+	return res
+}
+
+// rewriteLinks rewrites relative markdown links and wiki-links of a markdown text which is located at the key
+// (after the move) to follow the move. It ignores links in code blocks.
+func (m snippetMove) rewriteLinks(key string, text string) string {
+	oldDir, newDir := path.Dir(m.revert(key)), path.Dir(key)
+
+	lines := strings.Split(text, "\n")
+	inCode := false
+	for i, line := range lines {
+		if isFence(line) {
+			inCode = !inCode
+			continue
+		}
+
+		if inCode {
+			continue
+		}
+
+		line = mdLinkRegex.ReplaceAllStringFunc(line, func(s string) string {
+			sm := mdLinkRegex.FindStringSubmatch(s)
+			if target, ok := m.rewriteTarget(oldDir, newDir, sm[2]); ok {
+				return sm[1] + target + sm[3] + ")"
+			}
+			return s
+		})
+
+		lines[i] = wikiLinkRegex.ReplaceAllStringFunc(line, func(s string) string {
+			sm := wikiLinkRegex.FindStringSubmatch(s)
+			if name, ok := m.rewriteWikiName(strings.TrimSpace(sm[1])); ok {
+				return "[[" + name + sm[2] + "]]"
+			}
+			return s
+		})
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rewriteTarget returns the new target of a relative link of a file which is moved from the oldDir to the
+// newDir (they're the same if the file itself is not moved).
+func (m snippetMove) rewriteTarget(oldDir string, newDir string, target string) (string, bool) {
+	if u, err := url.Parse(target); err != nil || u.Scheme != "" || u.Host != "" {
+		return "", false
+	}
+
+	p, suffix := target, ""
+	if i := strings.IndexAny(target, "?#"); i != -1 {
+		p, suffix = target[:i], target[i:]
+	}
+
+	unescaped, err := url.PathUnescape(p)
+	if err != nil || unescaped == "" || strings.HasPrefix(unescaped, "/") {
+		return "", false
+	}
+
+	oldKey := path.Join(oldDir, unescaped)
+	if oldKey == ".." || strings.HasPrefix(oldKey, "../") { // Outside the snippets dir.
+		return "", false
+	}
+
+	newKey, moved := m.apply(oldKey)
+	if !moved && !strings.HasSuffix(oldKey, ".md") {
+		// Links to markdown files may omit the extension.
+		if k, ok := m.apply(oldKey + ".md"); ok {
+			newKey, moved = strings.TrimSuffix(k, ".md"), true
+		}
+	}
+
+	if !moved && oldDir == newDir {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(filepath.FromSlash(newDir), filepath.FromSlash(newKey))
+	if err != nil {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+
+	if strings.HasSuffix(unescaped, "/") {
+		rel += "/"
+	}
+	if strings.HasPrefix(p, "./") && !strings.HasPrefix(rel, "../") {
+		rel = "./" + rel
+	}
+	if p != unescaped {
+		rel = (&url.URL{Path: rel}).EscapedPath()
+	}
+
+	res := rel + suffix
+	return res, res != target
+}
+
+// rewriteWikiName returns the new name of a wiki-link. Wiki-links point to snippets by their path relative to
+// the snippets dir, or just by their file name, both usually without the markdown extension.
+func (m snippetMove) rewriteWikiName(name string) (string, bool) {
+	hasExt := strings.HasSuffix(name, ".md")
+	newName := func(key string) string {
+		if hasExt {
+			return key
+		}
+		return strings.TrimSuffix(key, ".md")
+	}
+
+	for _, key := range []string{name, name + ".md"} {
+		if newKey, ok := m.apply(key); ok {
+			return newName(newKey), newName(newKey) != name
+		}
+	}
+
+	// Link by the file name
+	if !m.IsDir && !strings.Contains(name, "/") && (name == path.Base(m.From) || name+".md" == path.Base(m.From)) {
+		res := newName(path.Base(m.To))
+		return res, res != name
+	}
+	return "", false
+}
+
+// resolveSnippetMove resolves the source and destination snippet names like Config.SnippetPath. Directories
+// must end with a '/', and if the source is a file and the destination is a directory, the file is moved into
+// the directory.
+func resolveSnippetMove(srcName string, dstName string) (snippetMove, error) {
+	src := Cfg.SnippetPath(srcName)
+	info, err := os.Stat(src)
+	if err != nil {
+		return snippetMove{}, err
+	}
+
+	dst := Cfg.SnippetPath(dstName)
+	if info.IsDir() {
+		dst = JoinPaths(Cfg.Dir, dstName)
+	} else if EndsWithDirectoryPath(dst) {
+		dst = filepath.Join(dst, filepath.Base(src))
+	}
+
+	m := snippetMove{IsDir: info.IsDir()}
+	for _, p := range []struct {
+		fpath string
+		key   *string
+	}{{src, &m.From}, {dst, &m.To}} {
+		rel, err := filepath.Rel(Cfg.Dir, filepath.Clean(p.fpath))
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return snippetMove{}, invalidArgument("invalid path: %s, it must be inside the snippets directory", p.fpath)
+		}
+		*p.key = filepath.ToSlash(rel)
+	}
+
+	if _, moved := m.apply(m.To); moved {
+		return snippetMove{}, invalidArgument("can not move or copy %s to itself or its subdirectory", srcName)
+	}
+	return m, nil
+}
+
+// moveSnippetFile moves the snippet file or directory. It uses git mv if the snippet is tracked by git, so its
+// history follows it. It returns true if it used git.
+func moveSnippetFile(m snippetMove) (bool, error) {
+	from, to := filepath.FromSlash(m.From), filepath.FromSlash(m.To)
+	if err := os.MkdirAll(filepath.Join(Cfg.Dir, filepath.Dir(to)), 0777); err != nil {
+		return false, fmt.Errorf("can not create the destination directory: %w", err)
+	}
+
+	if gitTracked(from) {
+		_, err := gitOutput("mv", "--", from, to)
+		return true, err
+	}
+	return false, os.Rename(filepath.Join(Cfg.Dir, from), filepath.Join(Cfg.Dir, to))
+}
+
+// replaceSnippetFile moves the snippet file or directory like moveSnippetFile, but it overwrites the existing
+// destination. The destination is moved aside until the move succeeds, so it's restored if the move fails.
+func replaceSnippetFile(m snippetMove) (bool, error) {
+	dst := filepath.Join(Cfg.Dir, filepath.FromSlash(m.To))
+	aside, err := os.MkdirTemp(filepath.Dir(dst), ".snip-mv-")
+	if err != nil {
+		return false, err
+	}
+
+	backup := filepath.Join(aside, filepath.Base(dst))
+	if err := os.Rename(dst, backup); err != nil {
+		_ = os.Remove(aside)
+		return false, err
+	}
+
+	git, err := moveSnippetFile(m)
+	if err != nil {
+		if restoreErr := os.Rename(backup, dst); restoreErr != nil {
+			return git, fmt.Errorf("%w, and can not restore %s from %s: %v", err, m.To, backup, restoreErr)
+		}
+		_ = os.Remove(aside)
+		return git, err
+	}
+	return git, os.RemoveAll(aside)
+}
+
+// rewriteSnippetsLinks rewrites links of all markdown snippets to follow the move. It returns keys of the
+// updated snippets.
+func rewriteSnippetsLinks(m snippetMove) ([]string, error) {
+	keys, err := listSnippets(Cfg.Dir, "", Cfg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, key := range keys {
+		if !isMarkdown(key) {
+			continue
+		}
+
+		fpath := filepath.Join(Cfg.Dir, filepath.FromSlash(key))
+		b, err := os.ReadFile(fpath)
+		if err != nil {
+			return nil, err
+		}
+
+		text := m.rewriteLinks(key, string(b))
+		if text == string(b) {
+			continue
+		}
+
+		info, err := os.Stat(fpath)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(fpath, []byte(text), info.Mode().Perm()); err != nil {
+			return nil, err
+		}
+		res = append(res, key)
+	}
+	return res, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSnippetMove_Apply(t *testing.T) {
+	file := snippetMove{From: "a/b.md", To: "c/d.md"}
+	dir := snippetMove{From: "a", To: "x/y", IsDir: true}
+
+	cases := []struct {
+		tag   string
+		m     snippetMove
+		key   string
+		res   string
+		moved bool
+	}{
+		{tag: "t1", m: file, key: "a/b.md", res: "c/d.md", moved: true},
+		{tag: "t2", m: file, key: "a/b.mdx", res: "a/b.mdx"},
+		{tag: "t3", m: dir, key: "a/b.md", res: "x/y/b.md", moved: true},
+		{tag: "t4", m: dir, key: "a", res: "x/y", moved: true},
+		{tag: "t5", m: dir, key: "ab/c.md", res: "ab/c.md"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			res, moved := c.m.apply(c.key)
+			assertEqual(t, res, c.res)
+			assertEqual(t, moved, c.moved)
+		})
+	}
+
+	assertEqual(t, dir.revert("x/y/b.md"), "a/b.md")
+	assertEqual(t, dir.revert("z.md"), "z.md")
+}
+
+func TestSnippetMove_RewriteLinks(t *testing.T) {
+	m := snippetMove{From: "git/rebase.md", To: "git/history/rebase-guide.md"}
+
+	cases := []struct {
+		tag  string
+		key  string
+		text string
+		res  string
+	}{
+		{tag: "t1", key: "git/tips.md", text: "see [rebase](rebase.md#squash) and ![x](./rebase.md \"t\")",
+			res: "see [rebase](history/rebase-guide.md#squash) and ![x](./history/rebase-guide.md \"t\")"},
+		{tag: "t2", key: "index.md", text: "[r](git/rebase) [w](https://a.com/git/rebase.md) [abs](/git/rebase.md)",
+			res: "[r](git/history/rebase-guide) [w](https://a.com/git/rebase.md) [abs](/git/rebase.md)"},
+		{tag: "t3", key: "index.md", text: "[[git/rebase]] [[rebase|Rebase]] [[git/rebase.md#squash]] [[other]]",
+			res: "[[git/history/rebase-guide]] [[rebase-guide|Rebase]] [[git/history/rebase-guide.md#squash]] [[other]]"},
+		{tag: "t4", key: "index.md", text: "```\n[r](git/rebase.md)\n```\n[r](git/rebase.md)",
+			res: "```\n[r](git/rebase.md)\n```\n[r](git/history/rebase-guide.md)"},
+		// Links of the moved file itself
+		{tag: "t5", key: "git/history/rebase-guide.md", text: "[tips](tips.md) [me](rebase.md) [out](../../x.md) [a](#b)",
+			res: "[tips](../tips.md) [me](rebase-guide.md) [out](../../x.md) [a](#b)"},
+		{tag: "t6", key: "a b.md", text: "[r](git/rebase.md) [s](my%20file.md)",
+			res: "[r](git/history/rebase-guide.md) [s](my%20file.md)"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			assertEqual(t, m.rewriteLinks(c.key, c.text), c.res)
+		})
+	}
+
+	// Directories
+	m = snippetMove{From: "k8s", To: "ops/k8s", IsDir: true}
+	assertEqual(t, m.rewriteLinks("index.md", "[a](k8s/a.md) [d](k8s/) [[k8s/a]] [my](my%20k8s/a.md)"),
+		"[a](ops/k8s/a.md) [d](ops/k8s/) [[ops/k8s/a]] [my](my%20k8s/a.md)")
+	assertEqual(t, m.rewriteLinks("ops/k8s/a.md", "[b](b.md) [i](../index.md)"), "[b](b.md) [i](../../index.md)")
+}
+
+func TestMoveSnippetFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir, Git: "git"}
+	makeTree(t, tmpDir, "a.md", "b.md")
+
+	git, err := moveSnippetFile(snippetMove{From: "a.md", To: "x/a.md"})
+	assertEqual(t, err, nil)
+	assertTrue(t, !git)
+	assertExists(t, tmpDir, "x/a.md")
+
+	// Tracked files must be moved by git.
+	initGitRepo(t, tmpDir)
+	runGit(t, tmpDir, "add", "-A")
+	runGit(t, tmpDir, "commit", "-q", "-m", "init")
+	git, err = moveSnippetFile(snippetMove{From: "b.md", To: "y/b.md"})
+	assertEqual(t, err, nil)
+	assertTrue(t, git)
+	assertEqual(t, runGit(t, tmpDir, "status", "--porcelain"), "R  b.md -> y/b.md\n")
+}
+
+func TestReplaceSnippetFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir, Git: "git"}
+	makeTree(t, tmpDir, "a.md", "b.md", "c.md")
+
+	git, err := replaceSnippetFile(snippetMove{From: "a.md", To: "b.md"})
+	assertEqual(t, err, nil)
+	assertTrue(t, !git)
+	b, _ := os.ReadFile(path.Join(tmpDir, "b.md"))
+	assertEqual(t, string(b), "The "+path.Join(tmpDir, "a.md")+" file")
+
+	// The destination must be restored if the move fails.
+	initGitRepo(t, tmpDir)
+	runGit(t, tmpDir, "add", "-A")
+	runGit(t, tmpDir, "commit", "-q", "-m", "init")
+	Cfg.Git = path.Join(t.TempDir(), "git")
+	script := "#!/bin/sh\n[ \"$3\" = mv ] && exit 1\nexec git \"$@\"\n"
+	assertEqual(t, os.WriteFile(Cfg.Git, []byte(script), 0755), nil)
+
+	_, err = replaceSnippetFile(snippetMove{From: "b.md", To: "c.md"})
+	assertTrue(t, err != nil)
+	b, _ = os.ReadFile(path.Join(tmpDir, "c.md"))
+	assertEqual(t, string(b), "The "+path.Join(tmpDir, "c.md")+" file")
+	entries, _ := os.ReadDir(tmpDir)
+	assertEqual(t, len(entries), 3) // .git, b.md and c.md
+}
+
+func TestRewriteSnippetsLinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir, Exclude: []string{".git"}}
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "a.md"), []byte("[b](c/b.md)"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "a.txt"), []byte("[b](c/b.md)"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "d.md"), []byte("nothing"), 0644), nil)
+
+	updated, err := rewriteSnippetsLinks(snippetMove{From: "c/b.md", To: "b.md"})
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, updated, []string{"a.md"})
+
+	b, _ := os.ReadFile(path.Join(tmpDir, "a.md"))
+	assertEqual(t, string(b), "[b](b.md)")
+	b, _ = os.ReadFile(path.Join(tmpDir, "a.txt"))
+	assertEqual(t, string(b), "[b](c/b.md)")
+}
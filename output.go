@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+var FlagOutput = OutputText
+
+var outputFormats = []string{OutputText, OutputJSON, OutputYAML}
+
+// Error codes of the structured error documents.
+const (
+	ErrCodeNotFound        = "not_found"
+	ErrCodeAlreadyExists   = "already_exists"
+	ErrCodeInvalidArgument = "invalid_argument"
+	ErrCodeCommandFailed   = "command_failed" // An external command (e.g., git) failed.
+	ErrCodeUnknown         = "unknown"
+)
+
+// argumentError is returned when user provides an invalid argument or flag value.
+type argumentError struct {
+	msg string
+}
+
+func (e *argumentError) Error() string {
+	return e.msg
+}
+
+func invalidArgument(format string, a ...any) error {
+	return &argumentError{msg: fmt.Sprintf(format, a...)}
+}
+
+type ErrorDoc struct {
+	Error ErrorInfo `json:"error" yaml:"error"`
+}
+
+type ErrorInfo struct {
+	Code     string `json:"code" yaml:"code"`
+	Message  string `json:"message" yaml:"message"`
+	ExitCode int    `json:"exit_code,omitempty" yaml:"exit_code,omitempty"` // Exit code of the failed command.
+}
+
+type DirDoc struct {
+	Path string `json:"path" yaml:"path"`
+}
+
+type AddDoc struct {
+	Path     string `json:"path" yaml:"path"`
+	Size     int    `json:"size" yaml:"size"` // Size of the written contents in bytes.
+	Appended bool   `json:"appended" yaml:"appended"`
+}
+
+type RemoveDoc struct {
+	Path      string `json:"path" yaml:"path"`
+	Recursive bool   `json:"recursive" yaml:"recursive"`
+	TrashID   string `json:"trash_id,omitempty" yaml:"trash_id,omitempty"` // Empty if the trash is disabled.
+}
+
+type TrashItemDoc struct {
+	ID        string `json:"id" yaml:"id"`
+	Path      string `json:"path" yaml:"path"` // The original path relative to the snippets dir.
+	IsDir     bool   `json:"is_dir" yaml:"is_dir"`
+	DeletedAt string `json:"deleted_at" yaml:"deleted_at"` // RFC 3339
+}
+
+type MoveDoc struct {
+	From         string   `json:"from" yaml:"from"`
+	To           string   `json:"to" yaml:"to"`
+	Git          bool     `json:"git" yaml:"git"`                     // Whether it's moved by git mv.
+	UpdatedLinks []string `json:"updated_links" yaml:"updated_links"` // Snippets whose links are rewritten.
+}
+
+type DuplicateDoc struct {
+	From  string   `json:"from" yaml:"from"`
+	To    string   `json:"to" yaml:"to"`
+	Files []string `json:"files" yaml:"files"` // The copied files.
+}
+
+type ConfigSettingDoc struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+}
+
+type DoctorCheckDoc struct {
+	Name    string `json:"name" yaml:"name"`
+	Status  string `json:"status" yaml:"status"` // ok, warn or fail
+	Message string `json:"message" yaml:"message"`
+	Fix     string `json:"fix,omitempty" yaml:"fix,omitempty"`
+}
+
+type ProfileDoc struct {
+	Name    string `json:"name" yaml:"name"`
+	Dir     string `json:"dir" yaml:"dir"`
+	Current bool   `json:"current" yaml:"current"`
+}
+
+type StatusDoc struct {
+	Branch     string   `json:"branch" yaml:"branch"`
+	Upstream   string   `json:"upstream" yaml:"upstream"` // Empty if the branch has no upstream branch.
+	Ahead      int      `json:"ahead" yaml:"ahead"`
+	Behind     int      `json:"behind" yaml:"behind"`
+	New        []string `json:"new" yaml:"new"`
+	Modified   []string `json:"modified" yaml:"modified"`
+	Deleted    []string `json:"deleted" yaml:"deleted"`
+	Conflicted []string `json:"conflicted" yaml:"conflicted"`
+}
+
+type SyncDoc struct {
+	Message   string `json:"message" yaml:"message"` // The commit message of the local changes, empty if there were none.
+	Committed bool   `json:"committed" yaml:"committed"`
+	Pushed    bool   `json:"pushed" yaml:"pushed"`
+	Conflicts int    `json:"conflicts" yaml:"conflicts"` // Number of the resolved conflicted files.
+	Aborted   bool   `json:"aborted" yaml:"aborted"`
+}
+
+type VersionDoc struct {
+	Version string `json:"version" yaml:"version"`
+	Commit  string `json:"commit" yaml:"commit"`
+	Date    string `json:"date" yaml:"date"`
+}
+
+type CompletionDoc struct {
+	Shell  string `json:"shell" yaml:"shell"`
+	Script string `json:"script" yaml:"script"`
+}
+
+type SnippetDoc struct {
+	Name string  `json:"name" yaml:"name"`
+	Path string  `json:"path" yaml:"path"`
+	Meta Snippet `json:"meta" yaml:"meta"`
+	Body string  `json:"body" yaml:"body"`
+}
+
+type CodeBlockDoc struct {
+	Name    string `json:"name" yaml:"name"`
+	Index   int    `json:"index" yaml:"index"` // Starts from 1.
+	Info    string `json:"info" yaml:"info"`
+	Heading string `json:"heading,omitempty" yaml:"heading,omitempty"`
+	Code    string `json:"code" yaml:"code"`
+}
+
+type CopyDoc struct {
+	Name    string `json:"name" yaml:"name"`
+	Size    int    `json:"size" yaml:"size"`       // Size of the copied text in bytes.
+	Backend string `json:"backend" yaml:"backend"` // osc52 or command
+}
+
+type UseDoc struct {
+	Name      string            `json:"name" yaml:"name"`
+	Variables map[string]string `json:"variables" yaml:"variables"`
+	Text      string            `json:"text" yaml:"text"` // The rendered snippet.
+}
+
+type RunDoc struct {
+	Name   string `json:"name" yaml:"name"`
+	Shell  string `json:"shell" yaml:"shell"`
+	Script string `json:"script" yaml:"script"`
+	DryRun bool   `json:"dry_run" yaml:"dry_run"`
+}
+
+type ListItemDoc struct {
+	Name      string `json:"name" yaml:"name"`
+	Path      string `json:"path" yaml:"path"`
+	IsDir     bool   `json:"is_dir" yaml:"is_dir"`
+	Size      int64  `json:"size" yaml:"size"`
+	ModTime   string `json:"mod_time" yaml:"mod_time"` // RFC 3339
+	GitStatus string `json:"git_status,omitempty" yaml:"git_status,omitempty"`
+	Title     string `json:"title,omitempty" yaml:"title,omitempty"`
+}
+
+type IndexDoc struct {
+	File    string `json:"file" yaml:"file"`
+	Entries int    `json:"entries" yaml:"entries"`
+}
+
+type InitDoc struct {
+	Dir       string `json:"dir" yaml:"dir"`
+	Remote    string `json:"remote" yaml:"remote"` // The remote URL, empty if the repository has no remote.
+	Branch    string `json:"branch" yaml:"branch"`
+	Cloned    bool   `json:"cloned" yaml:"cloned"`
+	Committed bool   `json:"committed" yaml:"committed"` // The initial commit is created.
+	Pushed    bool   `json:"pushed" yaml:"pushed"`
+}
+
+// isStructuredOutput reports whether the output format is a machine-readable format.
+func isStructuredOutput() bool {
+	return FlagOutput != OutputText
+}
+
+// outputFlagValue returns the value of the output flag in the command-line args, or an empty string if it's not
+// given. Cobra validates flags and args before running boot, so we scan the args to print those errors in the
+// output format too.
+func outputFlagValue(args []string) string {
+	var res string
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return res
+		case arg == "-o" || arg == "--output":
+			if i+1 < len(args) {
+				res = args[i+1]
+			}
+		case strings.HasPrefix(arg, "--output="):
+			res = strings.TrimPrefix(arg, "--output=")
+		case strings.HasPrefix(arg, "-o"):
+			res = strings.TrimPrefix(strings.TrimPrefix(arg, "-o"), "=")
+		}
+	}
+	return res
+}
+
+// printOutput prints the document in the output format. In the text format, it calls the text function.
+func printOutput(w io.Writer, doc any, text func(w io.Writer) error) error {
+	switch FlagOutput {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case OutputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return text(w)
+	}
+}
+
+// noText is used for commands which print their progress messages in the text output format, so they have
+// nothing to print at the end.
+func noText(io.Writer) error {
+	return nil
+}
+
+// printError prints the error document of the error.
+func printError(w io.Writer, appErr error) error {
+	return printOutput(w, errorDoc(appErr), func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, appErr)
+		return err
+	})
+}
+
+func errorDoc(err error) ErrorDoc {
+	doc := ErrorDoc{Error: ErrorInfo{Code: ErrCodeUnknown, Message: err.Error()}}
+
+	var argErr *argumentError
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		doc.Error.Code = ErrCodeNotFound
+	case errors.Is(err, os.ErrExist):
+		doc.Error.Code = ErrCodeAlreadyExists
+	case errors.As(err, &argErr):
+		doc.Error.Code = ErrCodeInvalidArgument
+	case errors.As(err, &exitErr):
+		doc.Error.Code, doc.Error.ExitCode = ErrCodeCommandFailed, exitErr.ExitCode()
+	}
+	return doc
+}
+
+// progressWriter returns the writer which commands print their progress messages to. In structured
+// output formats, progress messages go to stderr to keep stdout parsable.
+func progressWriter() io.Writer {
+	if isStructuredOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestPrintOutput(t *testing.T) {
+	defer func() {
+		FlagOutput = OutputText // Reset it.
+	}()
+
+	doc := VersionDoc{Version: "1.0.0", Commit: "abc", Date: "today"}
+	text := func(w io.Writer) error {
+		_, err := fmt.Fprint(w, "text")
+		return err
+	}
+
+	cases := []struct {
+		format string
+		res    string
+	}{
+		{format: OutputText, res: "text"},
+		{format: OutputJSON, res: "{\n  \"version\": \"1.0.0\",\n  \"commit\": \"abc\",\n  \"date\": \"today\"\n}\n"},
+		{format: OutputYAML, res: "version: 1.0.0\ncommit: abc\ndate: today\n"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.format, func(t *testing.T) {
+			FlagOutput = c.format
+			var buf bytes.Buffer
+			assertEqual(t, printOutput(&buf, doc, text), nil)
+			assertEqual(t, buf.String(), c.res)
+			assertEqual(t, isStructuredOutput(), c.format != OutputText)
+		})
+	}
+}
+
+func TestErrorDoc(t *testing.T) {
+	_, notFoundErr := os.Stat("/a/b/c/d/e")
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+
+	cases := []struct {
+		tag      string
+		err      error
+		code     string
+		exitCode int
+	}{
+		{tag: "not found", err: notFoundErr, code: ErrCodeNotFound},
+		{tag: "exists", err: fmt.Errorf("abc: %w", os.ErrExist), code: ErrCodeAlreadyExists},
+		{tag: "invalid argument", err: invalidArgument("invalid %s", "abc"), code: ErrCodeInvalidArgument},
+		{tag: "command", err: exitErr, code: ErrCodeCommandFailed, exitCode: 3},
+		{tag: "unknown", err: errors.New("abc"), code: ErrCodeUnknown},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			doc := errorDoc(c.err)
+			assertEqual(t, doc.Error.Code, c.code)
+			assertEqual(t, doc.Error.Message, c.err.Error())
+			assertEqual(t, doc.Error.ExitCode, c.exitCode)
+		})
+	}
+}
+
+func TestPrintError(t *testing.T) {
+	defer func() {
+		FlagOutput = OutputText // Reset it.
+	}()
+
+	FlagOutput = OutputJSON
+	var buf bytes.Buffer
+	assertEqual(t, printError(&buf, invalidArgument("abc")), nil)
+	assertEqual(t, buf.String(), "{\n  \"error\": {\n    \"code\": \"invalid_argument\",\n    \"message\": \"abc\"\n  }\n}\n")
+}
+
+func TestOutputFlagValue(t *testing.T) {
+	assertEqual(t, outputFlagValue(nil), "")
+	assertEqual(t, outputFlagValue([]string{"ls", "-o", "json"}), "json")
+	assertEqual(t, outputFlagValue([]string{"-ojson", "ls"}), "json")
+	assertEqual(t, outputFlagValue([]string{"ls", "--output=yaml", "--output", "json"}), "json")
+	assertEqual(t, outputFlagValue([]string{"run", "a", "--", "-o", "json"}), "")
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var FlagProfile = ""
+
+// profileNameRegex matches valid profile names. Profile names are used as env prefixes (e.g., TASKS_DIR) and
+// shell function names, so we keep them simple.
+var profileNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// shellBuiltins are builtins and keywords of the shells which the profile wrapper functions must not shadow.
+var shellBuiltins = []string{
+	"alias", "bg", "bind", "break", "builtin", "case", "cd", "command", "continue", "declare", "do", "done", "echo",
+	"elif", "else", "end", "esac", "eval", "exec", "exit", "export", "false", "fg", "fi", "for", "function", "functions",
+	"history", "if", "in", "jobs", "kill", "let", "local", "popd", "printf", "pushd", "pwd", "read", "return", "select",
+	"set", "shift", "source", "switch", "test", "then", "time", "trap", "true", "type", "typeset", "ulimit", "umask",
+	"unalias", "unset", "until", "wait", "where", "which", "while",
+}
+
+// profile is a named section of the config file, e.g., [tasks].
+type profile struct {
+	Name string
+	Dir  string
+}
+
+// profileName returns the selected profile: the --profile flag, the {prefix}_PROFILE env variable, otherwise
+// the app name which lets symlinks of the binary act as implicit profiles.
+func profileName(appName string) (string, error) {
+	name := DefaultStr(FlagProfile, os.Getenv(prefix+"_PROFILE"))
+	if name == "" {
+		return appName, nil
+	}
+
+	return name, validateProfileName(name)
+}
+
+// validateProfileName returns an argument error if the profile name is invalid.
+func validateProfileName(name string) error {
+	if !profileNameRegex.MatchString(name) {
+		return invalidArgument("invalid profile name: %s, it must start with a lowercase letter and contain only lowercase letters, digits and underscores", name)
+	}
+	return nil
+}
+
+// shadowsCommand reports whether the profile's wrapper function (see genProfileCompletion) would shadow a command,
+// e.g., the ls profile would break the ls command.
+func shadowsCommand(name string) bool {
+	if slices.Contains(shellBuiltins, name) {
+		return true
+	}
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// listProfiles returns sections of the config file and the current profile sorted by their names.
+func listProfiles() ([]profile, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	names := Cfg.file.sections()
+	if !slices.Contains(names, Cfg.Profile) {
+		names = append(names, Cfg.Profile)
+		slices.Sort(names)
+	}
+
+	res := make([]profile, len(names))
+	for i, name := range names {
+		res[i] = profile{Name: name, Dir: Cfg.Dir}
+		if name == Cfg.Profile {
+			continue
+		}
+		if dir, _, ok := lookupSetting(Cfg.file, prefix, strings.ToUpper(name), "dir"); ok {
+			res[i].Dir = strings.TrimSuffix(dir, string(os.PathSeparator))
+		} else {
+			res[i].Dir = defaultDir(homeDir)
+		}
+	}
+	return res, nil
+}
+
+// addProfile adds a section to the config file for the profile and creates its snippets dir.
+func addProfile(name string, dir string) (profile, error) {
+	if err := validateProfileName(name); err != nil {
+		return profile{}, err
+	}
+	if shadowsCommand(name) {
+		return profile{}, invalidArgument("invalid profile name: %s, its completion function would shadow the %s command", name, name)
+	}
+	if slices.Contains(Cfg.file.sections(), name) {
+		return profile{}, fmt.Errorf("profile %s: %w", name, os.ErrExist)
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return profile{}, err
+	}
+
+	if err := writeConfigValue(name, "dir", dir); err != nil {
+		return profile{}, err
+	}
+	return profile{Name: name, Dir: dir}, os.MkdirAll(dir, 0777)
+}
+
+// removeProfile removes the section of the profile from the config file. It keeps the profile's snippets.
+func removeProfile(name string) (profile, error) {
+	if !slices.Contains(Cfg.file.sections(), name) {
+		return profile{}, fmt.Errorf("profile %s: %w", name, os.ErrNotExist)
+	}
+	profiles, err := listProfiles()
+	if err != nil {
+		return profile{}, err
+	}
+	idx := slices.IndexFunc(profiles, func(p profile) bool { return p.Name == name })
+
+	b, err := os.ReadFile(Cfg.ConfigFile)
+	if err != nil {
+		return profile{}, err
+	}
+	if err := os.WriteFile(Cfg.ConfigFile, []byte(removeConfigFileSection(string(b), name)), 0644); err != nil {
+		return profile{}, err
+	}
+	return profiles[idx], nil
+}
+
+// genProfileCompletion returns a wrapper function which runs the app with the profile and enables the app's
+// completion for it.
+func genProfileCompletion(shell string, appName string, name string) string {
+	env := prefix + "_PROFILE"
+	switch shell {
+	case "bash":
+		return fmt.Sprintf(`
+%[3]s() { %[1]s=%[3]s %[2]s "$@"; }
+complete -o default -F __start_%[2]s %[3]s
+`, env, appName, name)
+	case "zsh":
+		return fmt.Sprintf(`
+%[3]s() { %[1]s=%[3]s %[2]s "$@"; }
+compdef _%[2]s %[3]s
+`, env, appName, name)
+	case "fish":
+		return fmt.Sprintf(`
+function %[3]s
+    %[1]s=%[3]s %[2]s $argv
+end
+complete -c %[3]s -e
+complete -c %[3]s -n '__%[2]s_clear_perform_completion_once_result'
+complete -c %[3]s -n 'not __%[2]s_requires_order_preservation && __%[2]s_prepare_completions' -f -a '$__%[2]s_comp_results'
+complete -k -c %[3]s -n '__%[2]s_requires_order_preservation && __%[2]s_prepare_completions' -f -a '$__%[2]s_comp_results'
+`, env, appName, name)
+	default:
+		return ""
+	}
+}
+
+// genProfilesCompletion returns the wrapper functions of all profiles of the config file except the app itself.
+// Profiles which would shadow a command are skipped.
+func genProfilesCompletion(shell string, appName string) string {
+	var b strings.Builder
+	for _, name := range Cfg.file.sections() {
+		if name == appName || !profileNameRegex.MatchString(name) {
+			continue
+		}
+
+		script := genProfileCompletion(shell, appName, name)
+		if script != "" && shadowsCommand(name) {
+			script = fmt.Sprintf("\n# The %[1]s profile is skipped, its function would shadow the %[1]s command.\n", name)
+		}
+		b.WriteString(script)
+	}
+	return b.String()
+}
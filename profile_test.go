@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProfileName(t *testing.T) {
+	setEnv(t, prefix+"_PROFILE", "")
+	name, err := profileName("tasks") // The app name is an implicit profile.
+	assertEqual(t, err, nil)
+	assertEqual(t, name, "tasks")
+
+	setEnv(t, prefix+"_PROFILE", "work")
+	name, _ = profileName("tasks")
+	assertEqual(t, name, "work")
+
+	FlagProfile = "home_2"
+	defer func() {
+		FlagProfile = "" // Reset it.
+	}()
+	name, _ = profileName("tasks")
+	assertEqual(t, name, "home_2")
+
+	for _, v := range []string{"Home", "2home", "my-home", "a b"} {
+		FlagProfile = v
+		_, err = profileName("tasks")
+		assertTrue(t, err != nil)
+	}
+}
+
+func TestListProfiles(t *testing.T) {
+	Cfg = &Config{Dir: "/snippets", Profile: "snip", file: configFile{
+		"dir":   "/global",
+		"work":  map[string]any{"dir": "/work/"},
+		"tasks": map[string]any{"editor": "vi"},
+	}}
+
+	res, err := listProfiles()
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, res, []profile{{Name: "snip", Dir: "/snippets"}, {Name: "tasks", Dir: "/global"}, {Name: "work", Dir: "/work"}})
+
+	Cfg.Profile = "work"
+	res, _ = listProfiles()
+	assertEqualSlice(t, res, []profile{{Name: "tasks", Dir: "/global"}, {Name: "work", Dir: "/snippets"}})
+}
+
+func TestShadowsCommand(t *testing.T) {
+	assertTrue(t, shadowsCommand("sh"))
+	assertTrue(t, shadowsCommand("cd"))
+	assertTrue(t, !shadowsCommand("snip_no_such_command"))
+}
+
+func TestGenProfilesCompletion(t *testing.T) {
+	Cfg = &Config{file: configFile{"snip": map[string]any{}, "tasks": map[string]any{}, "Bad-Name": map[string]any{}}}
+
+	assertEqual(t, genProfilesCompletion("bash", "snip"), "\ntasks() { SNIP_PROFILE=tasks snip \"$@\"; }\ncomplete -o default -F __start_snip tasks\n")
+
+	// Profiles must not shadow commands.
+	Cfg.file["sh"], Cfg.file["time"] = map[string]any{}, map[string]any{}
+	res := genProfilesCompletion("zsh", "snip")
+	assertTrue(t, strings.Contains(res, "\n# The sh profile is skipped, its function would shadow the sh command.\n"))
+	assertTrue(t, strings.Contains(res, "\n# The time profile is skipped, its function would shadow the time command.\n"))
+	assertTrue(t, !strings.Contains(res, "compdef _snip sh\n"))
+	delete(Cfg.file, "sh")
+	delete(Cfg.file, "time")
+
+	assertEqual(t, genProfilesCompletion("zsh", "snip"), "\ntasks() { SNIP_PROFILE=tasks snip \"$@\"; }\ncompdef _snip tasks\n")
+	assertTrue(t, strings.Contains(genProfilesCompletion("fish", "snip"), "complete -c tasks -e\n"))
+	assertEqual(t, genProfilesCompletion("powershell", "snip"), "")
+}
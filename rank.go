@@ -0,0 +1,207 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	prefixMatchWeight = 0.5 // Weight of a term which just starts with a query term.
+)
+
+// Fields of a document which we rank.
+const (
+	fieldPath = iota
+	fieldTitle
+	fieldHeadings
+	fieldBody
+	numFields
+)
+
+// fieldWeights boosts matches in the path and title of the snippets.
+var fieldWeights = [numFields]float64{3, 3, 1.5, 1}
+
+type rankDoc struct {
+	Key   string // Relative path of the snippet to the snippets dir.
+	Terms [numFields]map[string]int
+	Lens  [numFields]int
+}
+
+func termFrequencies(terms []string) (map[string]int, int) {
+	res := make(map[string]int, len(terms))
+	for _, t := range terms {
+		res[t]++
+	}
+	return res, len(terms)
+}
+
+// contentTerms keeps terms of a snippet's contents.
+type contentTerms struct {
+	Title          string         `json:"title,omitempty"`       // The first heading of a snippet.
+	Description    string         `json:"description,omitempty"` // The front-matter description, we show it in completions.
+	Headings       map[string]int `json:"headings,omitempty"`
+	HeadingsLength int            `json:"headings_length,omitempty"`
+	Terms          map[string]int `json:"terms"` // Term frequencies of the whole contents.
+	Length         int            `json:"length,omitempty"`
+	Tags           []string       `json:"tags,omitempty"`
+}
+
+// newRankDoc creates a document from a snippet's path and (optional) contents.
+func newRankDoc(key string, contents contentTerms) rankDoc {
+	doc := rankDoc{Key: key}
+	doc.Terms[fieldPath], doc.Lens[fieldPath] = termFrequencies(tokenize(strings.TrimSuffix(key, ".md")))
+	doc.Terms[fieldTitle], doc.Lens[fieldTitle] = termFrequencies(tokenize(contents.Title))
+	doc.Terms[fieldHeadings], doc.Lens[fieldHeadings] = contents.Headings, contents.HeadingsLength
+	doc.Terms[fieldBody], doc.Lens[fieldBody] = contents.Terms, contents.Length
+	return doc
+}
+
+// analyzeContents extracts the title, heading terms, body terms and tags of a snippet. The front-matter title
+// and aliases are ranked as the title, and its description and tags as headings. Headings and hashtags are
+// extracted just from markdown snippets.
+func analyzeContents(key string, contents string) contentTerms {
+	var res contentTerms
+	markdown := isMarkdown(key)
+	meta, body := parseSnippetLenient(key, []byte(contents))
+	res.Tags = snippetTags(meta, body, markdown)
+	res.Description = meta.Description
+
+	headingTerms := tokenize(meta.Description + " " + strings.Join(meta.Tags, " "))
+	if markdown {
+		for i, h := range markdownHeadings(string(body)) {
+			if i == 0 {
+				res.Title = h
+			}
+			headingTerms = append(headingTerms, tokenize(h)...)
+		}
+	}
+
+	if meta.Title != "" {
+		res.Title = meta.Title
+	}
+	if len(meta.Aliases) != 0 {
+		res.Title += " " + strings.Join(meta.Aliases, " ")
+	}
+
+	res.Headings, res.HeadingsLength = termFrequencies(headingTerms)
+	res.Terms, res.Length = termFrequencies(tokenize(contents))
+	return res
+}
+
+// fileRankDoc reads a snippet and creates its document.
+func fileRankDoc(dir string, key string) rankDoc {
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(key)))
+	if err != nil { // e.g., it's a directory, rank it by its path.
+		return newRankDoc(key, contentTerms{})
+	}
+	return newRankDoc(key, analyzeContents(key, string(b)))
+}
+
+// bm25Scores scores documents using BM25 over their fields. Matches of each field are weighted by fieldWeights.
+func bm25Scores(docs []rankDoc, query string) []float64 {
+	queryTerms := tokenize(query)
+	scores := make([]float64, len(docs))
+	if len(queryTerms) == 0 || len(docs) == 0 {
+		return scores
+	}
+
+	var avgLens [numFields]float64
+	for _, doc := range docs {
+		for f := range doc.Lens {
+			avgLens[f] += float64(doc.Lens[f]) / float64(len(docs))
+		}
+	}
+
+	slices.Sort(queryTerms)
+	for _, qt := range slices.Compact(queryTerms) {
+		// Term frequencies of each field of each document.
+		tfs := make([][numFields]float64, len(docs))
+		n := 0 // Number of documents containing the term.
+		for i, doc := range docs {
+			found := false
+			for f := range doc.Terms {
+				tfs[i][f] = termFrequency(doc.Terms[f], qt)
+				found = found || tfs[i][f] != 0
+			}
+			if found {
+				n++
+			}
+		}
+
+		idf := math.Log(1 + (float64(len(docs))-float64(n)+0.5)/(float64(n)+0.5))
+		for i, doc := range docs {
+			for f := range tfs[i] {
+				tf := tfs[i][f]
+				if tf == 0 {
+					continue
+				}
+				norm := 1 - bm25B
+				if avgLens[f] > 0 {
+					norm += bm25B * float64(doc.Lens[f]) / avgLens[f]
+				}
+				scores[i] += fieldWeights[f] * idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+			}
+		}
+	}
+
+	return scores
+}
+
+// termFrequency returns frequency of the term in the terms. Terms which start with the term are counted too,
+// but with less weight, so partial words (e.g., in auto-completion) match too.
+func termFrequency(terms map[string]int, term string) float64 {
+	var tf float64
+	for t, count := range terms {
+		if t == term {
+			tf += float64(count)
+		} else if strings.HasPrefix(t, term) {
+			tf += prefixMatchWeight * float64(count)
+		}
+	}
+	return tf
+}
+
+// rankResults sorts results of the findFiles function by their relevance to the search. The order of
+// results with the same score doesn't change. idx is optional, if provided, contents of the snippets are
+// ranked too.
+func rankResults(results []string, search string, idx *Index) []string {
+	if search == "" || len(results) < 2 {
+		return results
+	}
+
+	docs := make([]rankDoc, len(results))
+	for i, res := range results {
+		key := strings.TrimPrefix(path.Clean(filepath.ToSlash(res)), "/")
+		if !strings.HasSuffix(res, "/") && idx != nil && idx.Entries[key+".md"] != nil {
+			key += ".md"
+		}
+		docs[i] = newRankDoc(key, contentTerms{})
+		if idx != nil {
+			docs[i] = idx.rankDoc(key)
+		}
+	}
+
+	scores := bm25Scores(docs, search)
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(scores[b], scores[a])
+	})
+
+	res := make([]string, len(results))
+	for i, o := range order {
+		res[i] = results[o]
+	}
+	return res
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestAnalyzeContents(t *testing.T) {
+	res := analyzeContents("a.md", "# Git Rebase\n\nsome text\n\n```bash\n# not a heading\n```\n## Squash commits")
+	assertEqual(t, res.Title, "Git Rebase")
+	assertEqual(t, res.HeadingsLength, 4)
+	assertEqual(t, res.Headings["squash"], 1)
+	assertEqual(t, res.Headings["not"], 0)
+	assertEqual(t, res.Terms["git"], 1)
+
+	res = analyzeContents("a.sh", "# comment\necho hi")
+	assertEqual(t, res.Title, "")
+	assertEqual(t, res.HeadingsLength, 0)
+
+	res = analyzeContents("a.md", "---\ntitle: Drain\naliases: [evict]\ndescription: drain a node\ntags: [k8s]\n---\n# Heading")
+	assertEqual(t, res.Title, "Drain evict")
+	assertEqual(t, res.Headings["k8s"], 1)
+	assertEqual(t, res.Headings["heading"], 1)
+}
+
+func TestTermFrequency(t *testing.T) {
+	terms := map[string]int{"check": 2, "checkout": 1, "abc": 1}
+	assertEqual(t, termFrequency(terms, "check"), 2.5)
+	assertEqual(t, termFrequency(terms, "abc"), 1.0)
+	assertEqual(t, termFrequency(terms, "xyz"), 0.0)
+}
+
+func TestBM25Scores(t *testing.T) {
+	docs := []rankDoc{
+		newRankDoc("a/b.md", analyzeContents("a/b.md", "nothing relevant")),
+		newRankDoc("c.md", analyzeContents("c.md", "drain the node\nkubectl drain node")),
+		newRankDoc("k8s/drain.md", analyzeContents("k8s/drain.md", "# Drain\nkubectl drain node")),
+		newRankDoc("d.md", analyzeContents("d.md", "# Drain nodes\ntext")),
+	}
+
+	scores := bm25Scores(docs, "drain")
+	assertEqual(t, scores[0], 0.0)
+	assertTrue(t, scores[2] > scores[3], scores)
+	assertTrue(t, scores[3] > scores[1], scores)
+	assertTrue(t, scores[1] > 0, scores)
+
+	// Empty query
+	scores = bm25Scores(docs, "")
+	assertEqualSlice(t, scores, []float64{0, 0, 0, 0})
+}
+
+func TestRankResults(t *testing.T) {
+	defer resetConfig()
+	Cfg = &Config{Verbose: false}
+	dir := t.TempDir()
+	makeTree(t, dir, "notes/a.md", "notes/b.md", "notes/c.txt")
+	assertEqual(t, os.WriteFile(path.Join(dir, "notes/b.md"), []byte("# Docker\ndocker system prune"), 0644), nil)
+
+	results := []string{"notes/a", "notes/b", "notes/c.txt"}
+	// Without the index, results are ranked by their paths.
+	assertEqualSlice(t, rankResults(results, "c", nil), []string{"notes/c.txt", "notes/a", "notes/b"})
+	assertEqualSlice(t, rankResults(results, "", nil), results)
+
+	idx, err := openIndex(path.Join(t.TempDir(), "index.json"), dir, nil)
+	assertEqual(t, err, nil)
+	assertEqual(t, idx.Update(true), nil)
+	assertEqualSlice(t, rankResults(results, "docker", idx), []string{"notes/b", "notes/a", "notes/c.txt"})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+var (
+	FlagRunBlock  = ""
+	FlagRunDryRun = false
+	FlagRunYes    = false
+)
+
+// shellLangs are languages of the code blocks which we can run.
+var shellLangs = []string{"bash", "sh", "shell", "zsh"}
+
+// runError is returned when the snippet's script exits with a non-zero code. we exit with the same code.
+type runError struct {
+	err *exec.ExitError
+}
+
+func (e *runError) Error() string {
+	return "the snippet script failed: " + e.err.Error()
+}
+
+func (e *runError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns the exit code of the app for the error.
+func exitCode(err error) int {
+	var runErr *runError
+	if errors.As(err, &runErr) && runErr.err.ExitCode() > 0 {
+		return runErr.err.ExitCode()
+	}
+	return 1
+}
+
+// snippetScripts returns executable scripts of a snippet: the whole body of .sh snippets, or shell code
+// blocks of markdown snippets.
+func snippetScripts(fpath string, body string) ([]string, error) {
+	if strings.HasSuffix(fpath, ".sh") {
+		return []string{body}, nil
+	}
+
+	if !isMarkdown(fpath) {
+		return nil, invalidArgument("can not run %s, only markdown and .sh snippets are runnable", fpath)
+	}
+
+	var res []string
+	for _, b := range markdownCodeBlocks(body) {
+		if isShellBlock(b) {
+			res = append(res, b.Code)
+		}
+	}
+
+	if len(res) == 0 {
+		return nil, invalidArgument("no shell code block (%s) found in %s", strings.Join(shellLangs, ", "), fpath)
+	}
+	return res, nil
+}
+
+// isShellBlock reports whether the code block is a shell code block which we can run.
+func isShellBlock(b codeBlock) bool {
+	return slices.Contains(shellLangs, b.Lang())
+}
+
+// snippetScript returns the script of a snippet to run. If the anchor is given, just the code block of the
+// markdown snippet which matches it is returned, otherwise all of its shell code blocks.
+func snippetScript(name string, anchor string) (string, error) {
+	fpath := Cfg.SnippetPath(name)
+	if anchor != "" {
+		blocks, err := readSnippetBlocks(fpath)
+		if err != nil {
+			return "", err
+		}
+
+		b, _, err := findBlock(blocks, anchor)
+		if err != nil {
+			return "", err
+		}
+		if !isShellBlock(b) {
+			return "", invalidArgument("can not run the code block %q, it's not a shell code block", anchor)
+		}
+		return b.Code, nil
+	}
+
+	b, err := os.ReadFile(fpath)
+	if err != nil {
+		return "", err
+	}
+
+	_, body, err := parseSnippet(fpath, b)
+	if err != nil {
+		return "", err
+	}
+
+	scripts, err := snippetScripts(fpath, string(body))
+	if err != nil {
+		return "", err
+	}
+	return joinScripts(scripts), nil
+}
+
+// joinScripts joins the scripts into one script.
+func joinScripts(scripts []string) string {
+	var b strings.Builder
+	for _, s := range scripts {
+		b.WriteString(s)
+		if !strings.HasSuffix(s, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// userShell returns the user's shell which we run scripts with.
+func userShell() string {
+	return DefaultStr(os.Getenv("SHELL"), "sh")
+}
+
+// scriptPreview returns the script to show to the user before running it.
+func scriptPreview(shell string, script string) string {
+	lines := strings.Split(strings.TrimRight(script, "\n"), "\n")
+	return fmt.Sprintf("Running with %s:\n  %s\n", shell, strings.Join(lines, "\n  "))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+)
+
+func TestSnippetScripts(t *testing.T) {
+	body := "# Deploy\n```bash\necho a\n```\n```yaml\nk: v\n```\n```sh\necho b\n```\n"
+	scripts, err := snippetScripts("a/deploy.md", body)
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, scripts, []string{"echo a\n", "echo b\n"})
+
+	scripts, err = snippetScripts("a/deploy.sh", body)
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, scripts, []string{body})
+
+	_, err = snippetScripts("a/deploy.md", "no code")
+	assertTrue(t, err != nil)
+	_, err = snippetScripts("a/deploy.yaml", body)
+	assertTrue(t, err != nil)
+}
+
+func TestJoinScripts(t *testing.T) {
+	assertEqual(t, joinScripts([]string{"echo a\n", "echo b"}), "echo a\necho b\n")
+	assertEqual(t, joinScripts(nil), "")
+}
+
+func TestExitCode(t *testing.T) {
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	exitErr, ok := err.(*exec.ExitError)
+	assertTrue(t, ok)
+
+	assertEqual(t, exitCode(&runError{err: exitErr}), 3)
+	assertEqual(t, exitCode(err), 1)
+}
+
+func TestScriptPreview(t *testing.T) {
+	assertEqual(t, scriptPreview("bash", "echo a\necho b\n"), "Running with bash:\n  echo a\n  echo b\n")
+}
+
+func TestSnippetScript(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir}
+	assertEqual(t, os.WriteFile(path.Join(tmpDir, "a.md"), []byte(blockTestText), 0644), nil)
+
+	res, err := snippetScript("a", "squash")
+	assertEqual(t, err, nil)
+	assertEqual(t, res, "git reset --soft HEAD~2\n")
+
+	res, err = snippetScript("a", "1")
+	assertEqual(t, err, nil)
+	assertEqual(t, res, "git rebase -i\n")
+
+	res, err = snippetScript("a", "")
+	assertEqual(t, err, nil)
+	assertEqual(t, res, "git rebase -i\ngit reset --soft HEAD~2\n")
+
+	_, err = snippetScript("a", "3") // Not a shell code block
+	assertTrue(t, err != nil)
+	_, err = snippetScript("a", "4")
+	assertTrue(t, err != nil)
+}
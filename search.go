@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxExcerptLen  = 160       // Max length of the excerpt of a matched line.
+	ellipsis       = "..."     // It marks the cut sides of an excerpt.
+	maxSearchLine  = 1 << 20   // Max length of a line that we scan in snippets.
+	binarySniffLen = 8000      // Number of bytes we check to detect binary files.
+	colorReset     = "\033[0m" // Reset terminal colors.
+	colorPath      = "\033[35m"
+	colorLine      = "\033[32m"
+	colorMatch     = "\033[1;31m"
+)
+
+var (
+	FlagSearchRegex         = false
+	FlagSearchCaseSensitive = false
+	FlagSearchDir           = ""
+	FlagSearchJSON          = false
+)
+
+type searchMatch struct {
+	Path    string   `json:"-" yaml:"-"`             // Snippet name (relative to the snippets dir, without .md extension)
+	Line    int      `json:"line" yaml:"line"`       // Line number, starting from 1.
+	Text    string   `json:"text" yaml:"text"`       // The excerpt of the matched line.
+	Matches [][2]int `json:"matches" yaml:"matches"` // Start and end offsets of the matches in the Text.
+
+	key string // Slash-separated path of the snippet relative to the snippets dir.
+}
+
+// searchResult is matches of a snippet.
+type searchResult struct {
+	Path    string        `json:"path" yaml:"path"`
+	Score   float64       `json:"score" yaml:"score"` // Relevance of the snippet to the query.
+	Matches []searchMatch `json:"matches" yaml:"matches"`
+}
+
+// newSearchPattern compiles the search query to a regex. If the query is not a regex, it'll be
+// matched literally.
+func newSearchPattern(query string, isRegex bool, caseSensitive bool) (*regexp.Regexp, error) {
+	if !isRegex {
+		query = regexp.QuoteMeta(query)
+	}
+	if !caseSensitive {
+		query = "(?i)" + query
+	}
+
+	re, err := regexp.Compile(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search pattern: %w", err)
+	}
+	return re, nil
+}
+
+// searchFiles walks the subDir of the snippets dir and returns lines of snippets that match the pattern.
+// exclude paths are relative to the snippets dir.
+func searchFiles(dir string, subDir string, pattern *regexp.Regexp, exclude []string) ([]searchMatch, error) {
+	Verbose("search files ", "dir: ", dir, " sub_dir: ", subDir, " pattern: ", pattern, " exclude: ", exclude)
+
+	var result []searchMatch
+	walkFn := func(key string, info os.FileInfo) error {
+		if info.IsDir() {
+			return nil
+		}
+
+		matches, err := searchFile(filepath.Join(dir, filepath.FromSlash(key)), pattern)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.FromSlash(strings.TrimSuffix(key, ".md"))
+		for i := range matches {
+			matches[i].Path, matches[i].key = name, key
+		}
+		result = append(result, matches...)
+		return nil
+	}
+
+	if err := walkSnippets(dir, subDir, exclude, walkFn); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// searchFile returns matched lines of a file. It ignores binary files.
+func searchFile(fpath string, pattern *regexp.Regexp) ([]searchMatch, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	if head, _ := r.Peek(binarySniffLen); bytes.IndexByte(head, 0) != -1 {
+		return nil, nil
+	}
+
+	var result []searchMatch
+	for line := 1; ; line++ {
+		text, err := readLine(r, maxSearchLine)
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !pattern.MatchString(text) {
+			continue
+		}
+
+		// Match the source line (so patterns of its leading spaces match too), then shift the matches into
+		// the excerpt of the trimmed line.
+		trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+		lead := len(text) - len(trimmed)
+		res, start, end := excerpt(strings.TrimRightFunc(trimmed, unicode.IsSpace), pattern)
+		prefix := 0
+		if start > 0 {
+			prefix = len(ellipsis)
+		}
+
+		m := searchMatch{Line: line, Text: res}
+		for _, loc := range pattern.FindAllStringIndex(text, -1) {
+			from, to := max(loc[0]-lead, start), min(loc[1]-lead, end)
+			if from < to { // Ignore empty matches and matches out of the excerpt.
+				m.Matches = append(m.Matches, [2]int{from - start + prefix, to - start + prefix})
+			}
+		}
+		result = append(result, m)
+	}
+}
+
+// readLine reads a line without its line ending. Lines which are longer than the limit are truncated, so a
+// minified file doesn't fail the search. It returns io.EOF if there are no more lines.
+func readLine(r *bufio.Reader, limit int) (string, error) {
+	var b []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		if len(b) < limit {
+			b = append(b, chunk[:min(len(chunk), limit-len(b))]...)
+		}
+		if !isPrefix {
+			return string(b), nil
+		}
+	}
+}
+
+// rankSearchMatches groups matches by their snippets and sorts snippets by their relevance to the query.
+// idx is optional, if it's nil, the snippets will be read to rank them.
+func rankSearchMatches(dir string, matches []searchMatch, query string, idx *Index) []searchResult {
+	var results []searchResult
+	var docs []rankDoc
+	for _, m := range matches {
+		if len(results) == 0 || results[len(results)-1].Path != m.Path {
+			results = append(results, searchResult{Path: m.Path})
+			if idx != nil {
+				docs = append(docs, idx.rankDoc(m.key))
+			} else {
+				docs = append(docs, fileRankDoc(dir, m.key))
+			}
+		}
+		results[len(results)-1].Matches = append(results[len(results)-1].Matches, m)
+	}
+
+	scores := bm25Scores(docs, query)
+	for i := range results {
+		results[i].Score = scores[i]
+	}
+
+	slices.SortStableFunc(results, func(a, b searchResult) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return results
+}
+
+// excerpt cuts long lines around the first match. It returns the excerpt and its bounds in the text, the
+// excerpt has an ellipsis on each side which is cut.
+func excerpt(text string, pattern *regexp.Regexp) (string, int, int) {
+	if len(text) <= maxExcerptLen {
+		return text, 0, len(text)
+	}
+
+	start := 0
+	if loc := pattern.FindStringIndex(text); loc != nil && loc[1] > maxExcerptLen {
+		start = max(loc[0]-maxExcerptLen/4, 0)
+	}
+	end := min(start+maxExcerptLen, len(text))
+
+	// Do not cut in the middle of a multibyte character.
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+
+	res := text[start:end]
+	if start > 0 {
+		res = ellipsis + res
+	}
+	if end < len(text) {
+		res = res + ellipsis
+	}
+	return res, start, end
+}
+
+// printSearchResults prints matches in the "path:line: excerpt" format.
+func printSearchResults(w io.Writer, results []searchResult, colorize bool) error {
+	for _, r := range results {
+		if err := printSearchMatches(w, r.Matches, colorize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printSearchMatches(w io.Writer, matches []searchMatch, colorize bool) error {
+	for _, m := range matches {
+		var err error
+		if colorize {
+			_, err = fmt.Fprintf(w, "%s%s%s:%s%d%s: %s\n",
+				colorPath, m.Path, colorReset, colorLine, m.Line, colorReset, highlight(m.Text, m.Matches))
+		} else {
+			_, err = fmt.Fprintf(w, "%s:%d: %s\n", m.Path, m.Line, m.Text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func highlight(text string, matches [][2]int) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m[0]])
+		b.WriteString(colorMatch + text[m[0]:m[1]] + colorReset)
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
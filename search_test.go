@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNewSearchPattern(t *testing.T) {
+	re, err := newSearchPattern("a.b", false, false)
+	assertEqual(t, err, nil)
+	assertTrue(t, re.MatchString("A.B"))
+	assertTrue(t, !re.MatchString("acb"))
+
+	re, err = newSearchPattern("a.b", true, true)
+	assertEqual(t, err, nil)
+	assertTrue(t, re.MatchString("acb"))
+	assertTrue(t, !re.MatchString("ACB"))
+
+	_, err = newSearchPattern("a(", true, false)
+	assertTrue(t, err != nil)
+}
+
+func TestSearchFiles(t *testing.T) {
+	defer resetConfig()
+	Cfg = &Config{Verbose: false}
+	dir := t.TempDir()
+	makeTree(t, dir, "a/b.md", "a/c.yaml", "d.txt", ".git/config")
+	assertEqual(t, os.WriteFile(path.Join(dir, "a/b.md"), []byte("# jq\n\njq 'group_by(.a)'\nnothing\nGROUP_BY again"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, "d.txt"), []byte("group_by"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, ".git/config"), []byte("group_by"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, "a/c.yaml"), []byte("group_by\x00"), 0644), nil)
+
+	cases := []struct {
+		tag           string
+		query         string
+		subDir        string
+		regex         bool
+		caseSensitive bool
+		res           []string
+	}{
+		{tag: "t1", query: "group_by", res: []string{"a/b:3", "a/b:5", "d.txt:1"}},
+		{tag: "t2", query: "group_by", caseSensitive: true, res: []string{"a/b:3", "d.txt:1"}},
+		{tag: "t3", query: "group_by", subDir: "a", res: []string{"a/b:3", "a/b:5"}},
+		{tag: "t4", query: "^no", regex: true, res: []string{"a/b:4"}},
+		{tag: "t5", query: "^no", res: nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			pattern, err := newSearchPattern(c.query, c.regex, c.caseSensitive)
+			assertEqual(t, err, nil)
+			matches, err := searchFiles(dir, c.subDir, pattern, []string{".git"})
+			assertEqual(t, err, nil)
+
+			var res []string
+			for _, m := range matches {
+				res = append(res, m.Path+":"+strconv.Itoa(m.Line))
+			}
+			assertEqualSlice(t, res, c.res)
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	pattern, _ := newSearchPattern("needle", false, false)
+	res, start, end := excerpt("a needle", pattern)
+	assertEqual(t, res, "a needle")
+	assertEqual(t, start, 0)
+	assertEqual(t, end, 8)
+
+	long := strings.Repeat("a", 200) + "needle" + strings.Repeat("b", 200)
+	res, start, end = excerpt(long, pattern)
+	assertTrue(t, strings.HasPrefix(res, "..."), res)
+	assertTrue(t, strings.HasSuffix(res, "..."), res)
+	assertTrue(t, strings.Contains(res, "needle"), res)
+	assertEqual(t, res, "..."+long[start:end]+"...")
+
+	res, _, _ = excerpt("needle"+strings.Repeat("b", 200), pattern)
+	assertTrue(t, strings.HasPrefix(res, "needle"), res)
+	assertEqual(t, len(res), maxExcerptLen+3)
+}
+
+func TestSearchFileMatches(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "a.md")
+	long := strings.Repeat("a", 200) + ".needle" + strings.Repeat("b", 200)
+	assertEqual(t, os.WriteFile(fpath, []byte("  \tindented.\n"+long+"\n"), 0644), nil)
+
+	highlights := func(pattern string) []string {
+		re, err := newSearchPattern(pattern, true, false)
+		assertEqual(t, err, nil)
+		matches, err := searchFile(fpath, re)
+		assertEqual(t, err, nil)
+
+		var res []string
+		for _, m := range matches {
+			for _, loc := range m.Matches {
+				res = append(res, m.Text[loc[0]:loc[1]])
+			}
+		}
+		return res
+	}
+
+	// The ellipsis isn't highlighted.
+	assertEqualSlice(t, highlights(`\.`), []string{".", "."})
+	assertEqualSlice(t, highlights(`\.needle`), []string{".needle"})
+	// Matches of the leading spaces are highlighted in the trimmed line.
+	assertEqualSlice(t, highlights(`^\s+indented`), []string{"indented"})
+}
+
+func TestPrintSearchMatches(t *testing.T) {
+	matches := []searchMatch{{Path: "a/b", Line: 3, Text: "jq group_by", Matches: [][2]int{{3, 11}}}}
+
+	var buf bytes.Buffer
+	assertEqual(t, printSearchMatches(&buf, matches, false), nil)
+	assertEqual(t, buf.String(), "a/b:3: jq group_by\n")
+
+	buf.Reset()
+	assertEqual(t, printSearchMatches(&buf, matches, true), nil)
+	assertTrue(t, strings.Contains(buf.String(), "jq "+colorMatch+"group_by"+colorReset), buf.String())
+}
+
+func TestRankSearchMatches(t *testing.T) {
+	defer resetConfig()
+	Cfg = &Config{Verbose: false}
+	dir := t.TempDir()
+	makeTree(t, dir, "a.md", "jq/group.md")
+	assertEqual(t, os.WriteFile(path.Join(dir, "a.md"), []byte("use jq and group_by here"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, "jq/group.md"), []byte("# jq group_by\njq 'group_by(.a)'"), 0644), nil)
+
+	pattern, _ := newSearchPattern("group_by", false, false)
+	matches, err := searchFiles(dir, "", pattern, nil)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(matches), 3)
+
+	results := rankSearchMatches(dir, matches, "group_by", nil)
+	assertEqual(t, len(results), 2)
+	assertEqual(t, results[0].Path, "jq/group")
+	assertEqual(t, len(results[0].Matches), 2)
+	assertEqual(t, results[1].Path, "a")
+	assertTrue(t, results[0].Score > results[1].Score, results)
+}
+
+func TestReadLine(t *testing.T) {
+	r := bufio.NewReaderSize(strings.NewReader("abcdefghijklmnopqrstuvwxyz\nb\r\nc"), 16)
+	for _, expected := range []string{"abcdefghij", "b", "c"} {
+		line, err := readLine(r, 10)
+		assertEqual(t, err, nil)
+		assertEqual(t, line, expected)
+	}
+	_, err := readLine(r, 10)
+	assertEqual(t, err, io.EOF)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const frontMatterDelimiter = "---"
+
+// Snippet is the metadata of a snippet, which can be defined in an optional YAML front-matter
+// block at the top of the snippet file. e.g.,
+//
+//	---
+//	description: Drain a kubernetes node
+//	tags: [k8s, ops]
+//	---
+type Snippet struct {
+	Title       string     `yaml:"title,omitempty" json:"title,omitempty"`
+	Description string     `yaml:"description,omitempty" json:"description,omitempty"`
+	Tags        stringList `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Aliases     stringList `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+	Language    string     `yaml:"language,omitempty" json:"language,omitempty"`
+	Author      string     `yaml:"author,omitempty" json:"author,omitempty"`
+}
+
+// stringList is a list of strings which can be written as a YAML sequence or a comma-separated string.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = nil
+		for _, v := range strings.Split(value.Value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				*l = append(*l, v)
+			}
+		}
+		return nil
+	}
+
+	var res []string
+	if err := value.Decode(&res); err != nil {
+		return err
+	}
+	*l = res
+	return nil
+}
+
+// splitFrontMatter splits the front-matter and the body of a snippet. If the snippet doesn't have
+// any front-matter, the front-matter will be nil.
+func splitFrontMatter(contents []byte) (frontMatter []byte, body []byte) {
+	firstLine, rest, _ := bytes.Cut(contents, []byte("\n"))
+	if string(bytes.TrimRight(firstLine, " \t\r")) != frontMatterDelimiter {
+		return nil, contents
+	}
+
+	offset := 0
+	for offset < len(rest) {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		next := min(offset+len(line)+1, len(rest))
+		if l := string(bytes.TrimRight(line, " \t\r")); l == frontMatterDelimiter || l == "..." {
+			return rest[:offset], rest[next:]
+		}
+		offset = next
+	}
+
+	return nil, contents // The front-matter isn't closed, so it's not a front-matter.
+}
+
+// parseSnippet parses the front-matter of a snippet and returns its metadata and its body. Only markdown
+// snippets have front-matter, e.g., a multi-document YAML snippet may start with "---" too.
+func parseSnippet(fname string, contents []byte) (Snippet, []byte, error) {
+	var s Snippet
+	if !isMarkdown(fname) {
+		return s, contents, nil
+	}
+	frontMatter, body := splitFrontMatter(contents)
+	if frontMatter == nil {
+		return s, body, nil
+	}
+
+	if err := yaml.Unmarshal(frontMatter, &s); err != nil {
+		return s, body, fmt.Errorf("invalid snippet front-matter: %w", err)
+	}
+	return s, body, nil
+}
+
+// parseSnippetLenient parses the snippet like parseSnippet, but it skips an invalid front-matter, so one
+// invalid snippet doesn't fail commands which read all snippets.
+func parseSnippetLenient(name string, contents []byte) (Snippet, []byte) {
+	meta, body, err := parseSnippet(name, contents)
+	if err != nil {
+		Verbose("skip the snippet front-matter. name: ", name, " err: ", err)
+	}
+	return meta, body
+}
+
+// readSnippetMeta reads just the front-matter of a snippet file, so it doesn't read the whole file.
+func readSnippetMeta(fpath string) (Snippet, error) {
+	if !isMarkdown(fpath) {
+		return Snippet{}, nil
+	}
+
+	f, err := os.Open(fpath)
+	if err != nil {
+		return Snippet{}, err
+	}
+	defer f.Close()
+
+	var head bytes.Buffer
+	r := bufio.NewReader(f)
+	for lineNum := 0; ; lineNum++ {
+		line, err := r.ReadBytes('\n')
+		head.Write(line)
+
+		l := string(bytes.TrimRight(line, " \t\r\n"))
+		if lineNum == 0 && l != frontMatterDelimiter {
+			return Snippet{}, nil
+		}
+		if lineNum != 0 && (l == frontMatterDelimiter || l == "...") {
+			break
+		}
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return Snippet{}, err
+		}
+	}
+
+	s, _, err := parseSnippet(fpath, head.Bytes())
+	return s, err
+}
+
+// snippetBodyFile returns path to a file which contains the snippet without its front-matter. If the
+// snippet doesn't have any front-matter (or it's not a markdown snippet), it returns the snippet path itself. The returned cleanup
+// function removes the temporary file.
+func snippetBodyFile(fpath string) (string, func(), error) {
+	noop := func() {}
+	if !isMarkdown(fpath) {
+		return fpath, noop, nil
+	}
+
+	contents, err := os.ReadFile(fpath)
+	if err != nil {
+		return "", noop, err
+	}
+
+	frontMatter, body := splitFrontMatter(contents)
+	if frontMatter == nil {
+		return fpath, noop, nil
+	}
+
+	// Keep the file name (and its extension), viewers detect the file type by its extension.
+	f, err := os.CreateTemp("", "snip-*-"+filepath.Base(fpath))
+	if err != nil {
+		return "", noop, err
+	}
+	cleanup := func() { _ = os.Remove(f.Name()) }
+
+	if _, err := f.Write(body); err != nil {
+		_ = f.Close()
+		cleanup()
+		return "", noop, err
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", noop, err
+	}
+	return f.Name(), cleanup, nil
+}
+
+// withDescriptions appends descriptions of the snippets to the completion results in the cobra's
+// "name\tdescription" format. It reads the descriptions from the index, and reads the snippets only if the
+// index is disabled (idx is nil).
+func withDescriptions(results []string, idx *Index) []string {
+	for i, res := range results {
+		if EndsWithDirectoryPath(res) {
+			continue
+		}
+
+		desc, err := snippetDescription(res, idx)
+		if err != nil {
+			Verbose("can not read the snippet description. name: ", res, " err: ", err)
+			continue
+		}
+		if desc != "" {
+			results[i] = res + "\t" + desc
+		}
+	}
+
+	if idx != nil {
+		if err := idx.Save(); err != nil {
+			Verbose("can not save the index. err: ", err)
+		}
+	}
+	return results
+}
+
+// snippetDescription returns the front-matter description of the snippet. The index analyzes the snippet
+// just once, until it changes.
+func snippetDescription(name string, idx *Index) (string, error) {
+	fpath := Cfg.SnippetPath(name)
+	if idx != nil {
+		if rel, err := filepath.Rel(Cfg.Dir, fpath); err == nil {
+			key := filepath.ToSlash(rel)
+			if e := idx.Entries[key]; e != nil && !e.IsDir {
+				if err := idx.updateFile(key); err != nil {
+					return "", err
+				}
+				return e.Description, nil
+			}
+		}
+	}
+
+	s, err := readSnippetMeta(fpath)
+	return s.Description, err
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	cases := []struct {
+		tag         string
+		contents    string
+		frontMatter string
+		body        string
+		hasMeta     bool
+	}{
+		{tag: "t1", contents: "# title\nbody", body: "# title\nbody"},
+		{tag: "t2", contents: "---\na: b\n---\n# title\n", frontMatter: "a: b\n", body: "# title\n", hasMeta: true},
+		{tag: "t3", contents: "---\r\na: b\r\n...\r\nbody", frontMatter: "a: b\r\n", body: "body", hasMeta: true},
+		{tag: "t4", contents: "---\n---\n", body: "", hasMeta: true},
+		{tag: "t5", contents: "---\na: b\n", body: "---\na: b\n"},
+		{tag: "t6", contents: "", body: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			frontMatter, body := splitFrontMatter([]byte(c.contents))
+			assertEqual(t, frontMatter != nil, c.hasMeta)
+			assertEqual(t, string(frontMatter), c.frontMatter)
+			assertEqual(t, string(body), c.body)
+		})
+	}
+}
+
+func TestParseSnippet(t *testing.T) {
+	contents := `---
+title: Drain
+description: Drain a node
+tags: k8s, ops
+aliases: [evict]
+language: bash
+author: me
+---
+kubectl drain node`
+
+	s, body, err := parseSnippet("a.md", []byte(contents))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(body), "kubectl drain node")
+	assertEqual(t, s.Title, "Drain")
+	assertEqual(t, s.Description, "Drain a node")
+	assertEqualSlice(t, s.Tags, stringList{"k8s", "ops"})
+	assertEqualSlice(t, s.Aliases, stringList{"evict"})
+	assertEqual(t, s.Language, "bash")
+	assertEqual(t, s.Author, "me")
+
+	_, _, err = parseSnippet("a.md", []byte("---\ntags: [a\n---\n"))
+	assertTrue(t, err != nil)
+
+	// Only markdown snippets have front-matter.
+	s, body, err = parseSnippet("ns.yaml", []byte(multiDocYAML))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(body), multiDocYAML)
+	assertEqual(t, s.Title, "")
+}
+
+// multiDocYAML is a multi-document YAML snippet, its first document looks like a front-matter.
+const multiDocYAML = `---
+title: [namespace]
+kind: Namespace
+---
+kind: Pod
+`
+
+func TestReadSnippetMeta(t *testing.T) {
+	dir := t.TempDir()
+	assertEqual(t, os.WriteFile(path.Join(dir, "a.md"), []byte("---\ndescription: abc\n---\nbody"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, "b.md"), []byte("body"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, "ns.yaml"), []byte(multiDocYAML), 0644), nil)
+
+	s, err := readSnippetMeta(path.Join(dir, "a.md"))
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Description, "abc")
+
+	s, err = readSnippetMeta(path.Join(dir, "b.md"))
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Description, "")
+
+	s, err = readSnippetMeta(path.Join(dir, "ns.yaml"))
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Description, "")
+
+	_, err = readSnippetMeta(path.Join(dir, "c.md"))
+	assertTrue(t, err != nil)
+}
+
+func TestSnippetBodyFile(t *testing.T) {
+	dir := t.TempDir()
+	assertEqual(t, os.WriteFile(path.Join(dir, "a.md"), []byte("---\ndescription: abc\n---\nbody"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, "b.md"), []byte("body"), 0644), nil)
+
+	fpath, cleanup, err := snippetBodyFile(path.Join(dir, "a.md"))
+	assertEqual(t, err, nil)
+	assertTrue(t, fpath != path.Join(dir, "a.md"))
+	assertEqual(t, path.Ext(fpath), ".md")
+	b, err := os.ReadFile(fpath)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(b), "body")
+	cleanup()
+	_, err = os.Stat(fpath)
+	assertTrue(t, os.IsNotExist(err))
+
+	fpath, cleanup, err = snippetBodyFile(path.Join(dir, "b.md"))
+	assertEqual(t, err, nil)
+	assertEqual(t, fpath, path.Join(dir, "b.md"))
+	cleanup()
+	assertExists(t, dir, "b.md")
+
+	assertEqual(t, os.WriteFile(path.Join(dir, "ns.yaml"), []byte(multiDocYAML), 0644), nil)
+	fpath, cleanup, err = snippetBodyFile(path.Join(dir, "ns.yaml"))
+	assertEqual(t, err, nil)
+	assertEqual(t, fpath, path.Join(dir, "ns.yaml"))
+	cleanup()
+}
+
+func TestWithDescriptions(t *testing.T) {
+	dir := t.TempDir()
+	Cfg = &Config{Dir: dir}
+	makeTree(t, dir, "a/b.md", "c.yaml", "d/")
+	assertEqual(t, os.WriteFile(path.Join(dir, "a/b.md"), []byte("---\ndescription: abc\n---\nbody"), 0644), nil)
+
+	res := withDescriptions([]string{"a/b", "c.yaml", "d/", "e"}, nil)
+	assertEqualSlice(t, res, []string{"a/b\tabc", "c.yaml", "d/", "e"})
+
+	// With the index, descriptions are kept in the index.
+	Cfg.IndexFile = path.Join(t.TempDir(), "snip.index.json")
+	idx, err := loadIndex(false)
+	assertEqual(t, err, nil)
+	res = withDescriptions([]string{"a/b", "c.yaml", "d/", "e"}, idx)
+	assertEqualSlice(t, res, []string{"a/b\tabc", "c.yaml", "d/", "e"})
+
+	idx, err = openIndex(Cfg.IndexFile, dir, nil)
+	assertEqual(t, err, nil)
+	assertEqual(t, idx.Entries["a/b.md"].Description, "abc")
+
+	// The index entry is used without reading the snippet again.
+	idx.Entries["a/b.md"].Description = "from the index"
+	res = withDescriptions([]string{"a/b"}, idx)
+	assertEqualSlice(t, res, []string{"a/b\tfrom the index"})
+}
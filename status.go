@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var FlagStatusShort = false
+
+// syncStatus is the state of the snippets which are not synced with the remote repository yet.
+type syncStatus struct {
+	Branch     string
+	Upstream   string // Empty if the branch has no upstream branch.
+	Ahead      int    // Number of the local commits which are not pushed.
+	Behind     int    // Number of the upstream commits which are not pulled (as of the last fetch).
+	New        []string
+	Modified   []string
+	Deleted    []string
+	Conflicted []string
+}
+
+// readSyncStatus reads the sync status of the snippets dir. Snippets are reported by their names.
+func readSyncStatus() (syncStatus, error) {
+	var res syncStatus
+	statuses, err := gitFileStatuses()
+	if err != nil {
+		return res, err
+	}
+
+	for fpath, status := range statuses {
+		if first, _, _ := strings.Cut(fpath, "/"); slices.Contains(Cfg.Exclude, first) {
+			continue
+		}
+
+		name := snippetName(fpath)
+		switch {
+		case strings.Contains(status, "U") || status == "AA" || status == "DD":
+			res.Conflicted = append(res.Conflicted, name)
+		case strings.Contains(status, "D"):
+			res.Deleted = append(res.Deleted, name)
+		case status == "??" || status[0] == 'A' || status[0] == 'R' || status[0] == 'C':
+			res.New = append(res.New, name)
+		default:
+			res.Modified = append(res.Modified, name)
+		}
+	}
+	for _, names := range [][]string{res.New, res.Modified, res.Deleted, res.Conflicted} {
+		slices.Sort(names)
+	}
+
+	// A new repository has no branch name until its first commit, so we don't fail on it.
+	res.Branch, _ = gitOutput("branch", "--show-current")
+	if res.Upstream = syncUpstream(); res.Upstream == "" {
+		return res, nil
+	}
+
+	counts, err := gitOutput("rev-list", "--left-right", "--count", "HEAD..."+res.Upstream)
+	if err != nil {
+		return res, err
+	}
+	ahead, behind, _ := strings.Cut(counts, "\t")
+	if res.Ahead, err = strconv.Atoi(ahead); err != nil {
+		return res, fmt.Errorf("invalid git rev-list output: %q", counts)
+	}
+	if res.Behind, err = strconv.Atoi(behind); err != nil {
+		return res, fmt.Errorf("invalid git rev-list output: %q", counts)
+	}
+	return res, nil
+}
+
+// syncUpstream returns the remote branch which the sync command syncs with, e.g., "origin/main". That's the
+// sync_branch of the sync remote if it's set, otherwise the upstream branch. It's empty if there is not any.
+func syncUpstream() string {
+	if Cfg.SyncBranch != "" {
+		upstream := DefaultStr(Cfg.SyncRemote, "origin") + "/" + Cfg.SyncBranch
+		if _, err := gitOutput("rev-parse", "-q", "--verify", "refs/remotes/"+upstream); err != nil {
+			return "" // It's not fetched yet.
+		}
+		return upstream
+	}
+	upstream, _ := gitOutput("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	return upstream
+}
+
+// short returns the status in a compact format which is suitable for shell prompts, e.g., "+1 ~2 -1 !1 ↑1 ↓2".
+// it's empty if everything is synced.
+func (s syncStatus) short() string {
+	var parts []string
+	for _, p := range []struct {
+		sign  string
+		count int
+	}{
+		{"+", len(s.New)}, {"~", len(s.Modified)}, {"-", len(s.Deleted)}, {"!", len(s.Conflicted)},
+		{"↑", s.Ahead}, {"↓", s.Behind},
+	} {
+		if p.count != 0 {
+			parts = append(parts, p.sign+strconv.Itoa(p.count))
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestReadSyncStatus(t *testing.T) {
+	dir, remote := t.TempDir(), t.TempDir()
+	Cfg = &Config{Dir: dir, Git: "git", Exclude: []string{".idea"}}
+	initGitRepo(t, dir)
+	runGit(t, remote, "init", "-q", "--bare")
+
+	makeTree(t, dir, "a.md", "b.sh", "c.md")
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+	runGit(t, dir, "branch", "-M", "main")
+
+	res, err := readSyncStatus()
+	assertEqual(t, err, nil)
+	assertEqual(t, res.Branch, "main")
+	assertEqual(t, res.Upstream, "")
+
+	runGit(t, dir, "remote", "add", "origin", remote)
+	runGit(t, dir, "push", "-q", "-u", "origin", "main")
+	assertEqual(t, os.WriteFile(path.Join(dir, "a.md"), []byte("changed"), 0644), nil)
+	runGit(t, dir, "commit", "-q", "-am", "change a")
+	assertEqual(t, os.Remove(path.Join(dir, "b.sh")), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, "c.md"), []byte("changed"), 0644), nil)
+	makeTree(t, dir, "k8s/drain.md", ".idea/workspace.xml")
+
+	res, err = readSyncStatus()
+	assertEqual(t, err, nil)
+	assertEqual(t, res.Upstream, "origin/main")
+	assertEqual(t, res.Ahead, 1)
+	assertEqual(t, res.Behind, 0)
+	assertEqualSlice(t, res.New, []string{path.Join("k8s", "drain")})
+	assertEqualSlice(t, res.Modified, []string{"c"})
+	assertEqualSlice(t, res.Deleted, []string{"b.sh"})
+	assertEqual(t, len(res.Conflicted), 0)
+
+	// The configured sync branch is used instead of the upstream branch.
+	runGit(t, dir, "push", "-q", "origin", "main:other")
+	runGit(t, dir, "fetch", "-q", "origin")
+	Cfg.SyncBranch = "other"
+	res, err = readSyncStatus()
+	assertEqual(t, err, nil)
+	assertEqual(t, res.Upstream, "origin/other")
+	assertEqual(t, res.Ahead, 0)
+	assertEqual(t, res.Behind, 0)
+
+	Cfg.SyncRemote, Cfg.SyncBranch = "origin", "unknown" // Not fetched.
+	res, err = readSyncStatus()
+	assertEqual(t, err, nil)
+	assertEqual(t, res.Upstream, "")
+	Cfg.SyncRemote, Cfg.SyncBranch = "", ""
+
+	// Conflicts
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "change c")
+	runGit(t, dir, "checkout", "-q", "-b", "other", "HEAD~2")
+	assertEqual(t, os.WriteFile(path.Join(dir, "c.md"), []byte("other"), 0644), nil)
+	runGit(t, dir, "commit", "-q", "-am", "other c")
+	_, err = gitOutput("merge", "main")
+	assertTrue(t, err != nil)
+
+	res, err = readSyncStatus()
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, res.Conflicted, []string{"c"})
+
+	Cfg.Dir = t.TempDir() // Not a git repository.
+	_, err = readSyncStatus()
+	assertTrue(t, err != nil)
+}
+
+func TestSyncStatusShort(t *testing.T) {
+	assertEqual(t, syncStatus{}.short(), "")
+	s := syncStatus{New: []string{"a"}, Modified: []string{"b", "c"}, Deleted: []string{"d"}, Conflicted: []string{"e"}, Ahead: 1, Behind: 2}
+	assertEqual(t, s.short(), "+1 ~2 -1 !1 ↑1 ↓2")
+	assertEqual(t, syncStatus{Behind: 3}.short(), "↓3")
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+var (
+	FlagSyncAbort     = false
+	FlagSyncRemote    = ""
+	FlagSyncBranch    = ""
+	FlagSyncStrategy  = ""
+	FlagSyncAutostash = false
+)
+
+// Pull strategies of the sync command.
+const (
+	SyncMerge  = "merge"
+	SyncRebase = "rebase"
+	SyncFFOnly = "ff-only"
+)
+
+var syncStrategies = []string{SyncMerge, SyncRebase, SyncFFOnly}
+
+// defaultSyncMessage is the default template of the sync commit message.
+const defaultSyncMessage = "snip: {{.Summary}}"
+
+// syncSummaryLimit is the length which the summary of the sync commit message is truncated after.
+const syncSummaryLimit = 60
+
+// Actions of the staged changes in the order which the summary lists them.
+var syncActions = []string{"add", "edit", "move", "remove"}
+
+// syncChange is a staged change of the sync commit.
+type syncChange struct {
+	Action string // One of the syncActions.
+	Name   string // The snippet name, "old to new" for moved snippets.
+}
+
+// syncMessageData is the data of the sync commit message template.
+type syncMessageData struct {
+	Summary string // e.g., "add k8s/drain, edit git/rebase, remove old/foo".
+	Changes []syncChange
+	Host    string
+	Profile string
+}
+
+// syncOptions are the remote and branch which the sync command pulls from and pushes to, and how it pulls.
+type syncOptions struct {
+	Remote    string `json:"remote"`
+	Branch    string `json:"branch"`
+	Strategy  string `json:"strategy"`
+	Autostash bool   `json:"autostash"`
+}
+
+// syncState is the state of the snippets repository before a sync. We keep it in the git dir until the sync
+// finishes, so the user can restore it by 'sync --abort', e.g., when the sync is interrupted by conflicts.
+type syncState struct {
+	Head    string      `json:"head"`    // Empty if the repository had no commits.
+	Commit  string      `json:"commit"`  // The commit of the local changes which the sync created, empty if there were none.
+	Created []string    `json:"created"` // Files which are created by resolving conflicts, relative to the snippets dir.
+	Options syncOptions `json:"options"` // Options of the sync, so resuming it continues the same pull.
+}
+
+// Choices of resolving a conflict.
+const (
+	ResolveMine   = "mine"
+	ResolveTheirs = "theirs"
+	ResolveEdit   = "edit"
+	ResolveBoth   = "both"
+)
+
+// errSyncQuit is returned when the user quits resolving conflicts.
+var errSyncQuit = errors.New("conflicts are not resolved, run sync again to resolve them or 'sync --abort' to restore the state before the sync")
+
+var (
+	conflictMarkerRegex = regexp.MustCompile(`(?m)^(<{7}|>{7})( |$)`) // Conflict markers which git adds to conflicted files.
+	hostNameRegex       = regexp.MustCompile(`[^A-Za-z0-9-]+`)
+)
+
+// resolveSyncOptions returns the sync options of the flags and settings, and validates them against the
+// repository, so we fail with a clear message before changing anything.
+func resolveSyncOptions() (syncOptions, error) {
+	res := syncOptions{
+		Remote:    DefaultStr(FlagSyncRemote, Cfg.SyncRemote, "origin"),
+		Branch:    DefaultStr(FlagSyncBranch, Cfg.SyncBranch),
+		Strategy:  DefaultStr(FlagSyncStrategy, Cfg.SyncStrategy, SyncMerge),
+		Autostash: FlagSyncAutostash,
+	}
+	if !slices.Contains(syncStrategies, res.Strategy) {
+		return res, invalidArgument("invalid sync strategy: %s, valid strategies: %s", res.Strategy, strings.Join(syncStrategies, ", "))
+	}
+	// Other strategies commit the changes before the pull, so there is nothing to stash.
+	if res.Autostash && res.Strategy != SyncFFOnly {
+		return res, invalidArgument("the --autostash flag can be used just with the %s strategy, the %s strategy commits your changes before the pull", SyncFFOnly, res.Strategy)
+	}
+	if _, err := gitOutput("remote", "get-url", res.Remote); err != nil {
+		return res, invalidArgument("the git repository has no %s remote, add it by 'git remote add %s {your_repo_remote_path}' or set the sync_remote setting (or --remote flag) to your remote", res.Remote, res.Remote)
+	}
+
+	current, _ := gitOutput("branch", "--show-current")
+	if current == "" {
+		return res, invalidArgument("HEAD is detached, checkout a branch to sync it")
+	}
+	if res.Branch == "" {
+		merge, err := gitOutput("config", "branch."+current+".merge")
+		if err != nil {
+			return res, invalidArgument("the %s branch has no upstream branch, set it by 'git push -u %s %s' or set the sync_branch setting (or --branch flag) to the remote branch", current, res.Remote, current)
+		}
+		res.Branch = strings.TrimPrefix(merge, "refs/heads/")
+	}
+	return res, nil
+}
+
+// parseSyncMessage parses the template of the sync commit message.
+func parseSyncMessage(text string) (*template.Template, error) {
+	res, err := template.New("sync_message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, invalidArgument("invalid sync_message template: %v", err)
+	}
+	return res, nil
+}
+
+// syncMessage renders the commit message of the staged changes by the template.
+func syncMessage(tmpl *template.Template) (string, error) {
+	changes, err := stagedChanges()
+	if err != nil {
+		return "", err
+	}
+
+	host, _ := os.Hostname()
+	host, _, _ = strings.Cut(host, ".")
+	data := syncMessageData{Summary: summarizeChanges(changes, syncSummaryLimit), Changes: changes, Host: host, Profile: Cfg.Profile}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", invalidArgument("invalid sync_message template: %v", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// stagedChanges returns the staged changes of the snippets dir.
+func stagedChanges() ([]syncChange, error) {
+	out, err := gitOutput("diff", "--cached", "--name-status", "--find-renames", "--relative", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	var res []syncChange
+	fields := strings.Split(strings.TrimRight(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, name := fields[i], snippetName(fields[i+1])
+		switch status[0] {
+		case 'A':
+			res = append(res, syncChange{Action: "add", Name: name})
+		case 'D':
+			res = append(res, syncChange{Action: "remove", Name: name})
+		case 'R', 'C': // They have the source and destination paths.
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("invalid git diff output: %q", out)
+			}
+			dst := snippetName(fields[i+2])
+			if status[0] == 'R' {
+				res = append(res, syncChange{Action: "move", Name: name + " to " + dst})
+			} else {
+				res = append(res, syncChange{Action: "add", Name: dst})
+			}
+			i++
+		default:
+			res = append(res, syncChange{Action: "edit", Name: name})
+		}
+	}
+	return res, nil
+}
+
+// summarizeChanges returns a summary of the changes grouped by their actions, e.g., "add a, b, remove c". Names
+// which don't fit in the limit are counted, e.g., "add a, b and 3 more".
+func summarizeChanges(changes []syncChange, limit int) string {
+	if len(changes) == 0 {
+		return "update snippets"
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, action := range syncActions {
+		first := true
+		for _, c := range changes {
+			if c.Action != action {
+				continue
+			}
+
+			part := c.Name
+			if first {
+				part = action + " " + part
+			}
+			if b.Len() != 0 {
+				part = ", " + part
+			}
+			if n != 0 && b.Len()+len(part) > limit {
+				return fmt.Sprintf("%s and %d more", b.String(), len(changes)-n)
+			}
+			b.WriteString(part)
+			first = false
+			n++
+		}
+	}
+	return b.String()
+}
+
+// pullArgs returns the git pull arguments of the sync options.
+func (o syncOptions) pullArgs() []string {
+	res := []string{"pull", "--no-rebase"}
+	switch o.Strategy {
+	case SyncRebase:
+		res[1] = "--rebase"
+	case SyncFFOnly:
+		res[1] = "--ff-only"
+	}
+	if o.Autostash {
+		res = append(res, "--autostash")
+	}
+	res = append(res, o.Remote)
+	if o.Branch != "" {
+		res = append(res, o.Branch)
+	}
+	return res
+}
+
+// pushArgs returns the git push arguments of the sync options.
+func (o syncOptions) pushArgs() []string {
+	if o.Branch == "" {
+		return []string{"push", o.Remote}
+	}
+	return []string{"push", o.Remote, "HEAD:refs/heads/" + o.Branch}
+}
+
+// syncStatePath returns the path of the sync state file in the git dir.
+func syncStatePath() (string, error) {
+	return gitPath("snip-sync.json")
+}
+
+// loadSyncState loads the state of the interrupted sync. It returns os.ErrNotExist if there is none.
+func loadSyncState() (syncState, error) {
+	var res syncState
+	fpath, err := syncStatePath()
+	if err != nil {
+		return res, err
+	}
+
+	b, err := os.ReadFile(fpath)
+	if err != nil {
+		return res, err
+	}
+	return res, json.Unmarshal(b, &res)
+}
+
+func saveSyncState(state syncState) error {
+	fpath, err := syncStatePath()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fpath, b, 0644)
+}
+
+func removeSyncState() error {
+	fpath, err := syncStatePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fpath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// beginSync saves the current state of the repository and the sync options, or returns the state of the
+// interrupted sync to resume it. An interrupted pull continues by the options which it has started with.
+func beginSync(opts syncOptions) (syncState, error) {
+	state, err := loadSyncState()
+	if errors.Is(err, os.ErrNotExist) {
+		state = syncState{}
+		state.Head, _ = gitOutput("rev-parse", "-q", "--verify", "HEAD") // The repository may have no commits yet.
+	} else if err != nil {
+		return state, err
+	}
+
+	if pullInProgress() == "" || state.Options.Remote == "" {
+		state.Options = opts
+	}
+	return state, saveSyncState(state)
+}
+
+// abortSync restores the state of the repository before the interrupted sync: it aborts the pull, and
+// uncommits the local changes which the sync has committed, so they're local changes again.
+func abortSync() error {
+	state, err := loadSyncState()
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("there is no interrupted sync to abort: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if kind := pullInProgress(); kind != "" {
+		if _, err := gitOutput(kind, "--abort"); err != nil {
+			return err
+		}
+	}
+
+	for _, fpath := range state.Created {
+		if err := os.Remove(filepath.Join(Cfg.Dir, fpath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if state.Head != "" {
+		if _, err := gitOutput("reset", "-q", "--hard", DefaultStr(state.Commit, state.Head)); err != nil {
+			return err
+		}
+		if _, err := gitOutput("reset", "-q", state.Head); err != nil {
+			return err
+		}
+	}
+	return removeSyncState()
+}
+
+// pullInProgress returns "merge" or "rebase" if the repository is in the middle of a merge or rebase,
+// otherwise it's empty.
+func pullInProgress() string {
+	if _, err := gitOutput("rev-parse", "-q", "--verify", "MERGE_HEAD"); err == nil {
+		return SyncMerge
+	}
+	for _, name := range []string{"rebase-merge", "rebase-apply"} {
+		fpath, err := gitPath(name)
+		if err != nil {
+			return ""
+		}
+		if _, err := os.Stat(fpath); err == nil {
+			return SyncRebase
+		}
+	}
+	return ""
+}
+
+// conflictSides returns the conflict stages of mine and theirs. A rebase replays our commits on theirs, so
+// the sides are swapped: "ours" is the upstream.
+func conflictSides() (mine string, theirs string) {
+	if pullInProgress() == SyncRebase {
+		return "3", "2"
+	}
+	return "2", "3"
+}
+
+// continuePull stages the resolved conflicts and continues the merge or rebase. A rebase may stop again on the
+// next commit with new conflicts.
+func continuePull() error {
+	if err := gitCommand("add", "-A").Run(); err != nil {
+		return err
+	}
+
+	switch pullInProgress() {
+	case SyncMerge:
+		return gitCommand("commit", "--no-edit").Run()
+	case SyncRebase:
+		// The resolution may drop all changes of our commit (e.g., keep theirs), then we skip it.
+		action := "--continue"
+		if err := gitCommand("diff", "--cached", "--quiet").Run(); err == nil {
+			action = "--skip"
+		}
+		cmd := gitCommand("rebase", action)
+		cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+		return cmd.Run()
+	default:
+		return nil
+	}
+}
+
+// conflictedFiles returns the conflicted files relative to the snippets dir.
+func conflictedFiles() ([]string, error) {
+	out, err := gitOutput("diff", "--name-only", "--diff-filter=U", "--relative", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, fpath := range strings.Split(out, "\x00") {
+		if fpath != "" {
+			res = append(res, fpath)
+		}
+	}
+	return res, nil
+}
+
+// conflictStages returns the stages of the conflicted file which exist: 1 for the common ancestor, 2 for
+// ours and 3 for theirs (see conflictSides). A missing stage means that side has removed the file.
+func conflictStages(fpath string) (map[string]bool, error) {
+	out, err := gitOutput("ls-files", "-u", "--", fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) >= 3 {
+			res[fields[2]] = true
+		}
+	}
+	return res, nil
+}
+
+// conflictCopyName returns the name of the copy of the conflicted file which keeps the version of this host,
+// e.g., "k8s/drain.conflict-laptop.md".
+func conflictCopyName(fpath string, host string) string {
+	host, _, _ = strings.Cut(host, ".")
+	host = hostNameRegex.ReplaceAllString(host, "-")
+	ext := filepath.Ext(fpath)
+	return strings.TrimSuffix(fpath, ext) + ".conflict-" + DefaultStr(host, "local") + ext
+}
+
+// keepConflictStage resolves the conflicted file by the version of the stage ("2" for ours, "3" for theirs).
+// It removes the file if that side has removed it.
+func keepConflictStage(fpath string, stage string) error {
+	stages, err := conflictStages(fpath)
+	if err != nil {
+		return err
+	}
+
+	if !stages[stage] {
+		_, err := gitOutput("rm", "-q", "--", fpath)
+		return err
+	}
+
+	side := "--ours"
+	if stage == "3" {
+		side = "--theirs"
+	}
+	if _, err := gitOutput("checkout", side, "--", fpath); err != nil {
+		return err
+	}
+	_, err = gitOutput("add", "--", fpath)
+	return err
+}
+
+// resolveConflict resolves the conflicted file by the user's choice. It records files which it creates in
+// the sync state.
+func resolveConflict(fpath string, choice string, state *syncState) error {
+	mine, theirs := conflictSides()
+	switch choice {
+	case ResolveMine:
+		return keepConflictStage(fpath, mine)
+	case ResolveTheirs:
+		return keepConflictStage(fpath, theirs)
+	case ResolveEdit:
+		editor := editorArgs(Cfg.EditorCMD, filepath.Join(Cfg.Dir, fpath), 0, 0)
+		if err := Command(editor[0], editor[1:]...).Run(); err != nil {
+			return err
+		}
+		b, err := os.ReadFile(filepath.Join(Cfg.Dir, fpath))
+		if err != nil {
+			return err
+		}
+		if conflictMarkerRegex.Match(b) {
+			return invalidArgument("%s still has conflict markers", fpath)
+		}
+		_, err = gitOutput("add", "--", fpath)
+		return err
+	case ResolveBoth:
+		stages, err := conflictStages(fpath)
+		if err != nil {
+			return err
+		}
+		if stages[mine] {
+			b, err := exec.Command(Cfg.Git, "-C", Cfg.Dir, "show", ":"+mine+":./"+fpath).Output()
+			if err != nil {
+				return fmt.Errorf("git show: %w", err)
+			}
+
+			host, _ := os.Hostname()
+			copyPath := conflictCopyName(fpath, host)
+			if err := os.WriteFile(filepath.Join(Cfg.Dir, copyPath), b, 0644); err != nil {
+				return err
+			}
+			state.Created = append(state.Created, copyPath)
+			if err := saveSyncState(*state); err != nil {
+				return err
+			}
+			if _, err := gitOutput("add", "--", copyPath); err != nil {
+				return err
+			}
+		}
+		return keepConflictStage(fpath, theirs)
+	default:
+		return invalidArgument("invalid conflict resolution: %s", choice)
+	}
+}
+
+// resolveConflicts asks the user how to resolve each conflicted file and resolves it.
+func resolveConflicts(r *bufio.Reader, w io.Writer, files []string, state *syncState) error {
+	choices := map[string]string{"m": ResolveMine, "t": ResolveTheirs, "e": ResolveEdit, "b": ResolveBoth}
+	for _, fpath := range files {
+		if _, err := fmt.Fprintf(w, "Conflict in %s\n", snippetName(fpath)); err != nil {
+			return err
+		}
+
+		for {
+			ans, err := StringPrompt(r, w, "Keep [m]ine, keep [t]heirs, [e]dit it with conflict markers, keep [b]oth or [q]uit? ")
+			if err != nil {
+				return err
+			}
+
+			ans = strings.ToLower(strings.TrimSpace(ans))
+			if ans == "q" {
+				return errSyncQuit
+			}
+			choice, ok := choices[ans]
+			if !ok {
+				continue
+			}
+
+			var argErr *argumentError
+			if err := resolveConflict(fpath, choice, state); errors.As(err, &argErr) {
+				_, _ = fmt.Fprintln(w, err) // e.g., conflict markers are left, ask again.
+				continue
+			} else if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+// makeSyncRepos makes a bare remote repository and two clones of it: the local one which has committed "mine"
+// changes, and the other one which has pushed conflicting "theirs" changes (a.md is changed and x.md is removed).
+func makeSyncRepos(t *testing.T) (local string, other string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	local, other = path.Join(dir, "local"), path.Join(dir, "other")
+	initGitRepo(t, dir) // Sets the git identity.
+	runGit(t, dir, "init", "-q", "--bare", "-b", "main", "remote")
+	runGit(t, dir, "clone", "-q", "remote", "local")
+	assertEqual(t, os.WriteFile(path.Join(local, "a.md"), []byte("base\n"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(local, "x.md"), []byte("x\n"), 0644), nil)
+	runGit(t, local, "add", "-A")
+	runGit(t, local, "commit", "-q", "-m", "base")
+	runGit(t, local, "push", "-q", "-u", "origin", "main")
+
+	runGit(t, dir, "clone", "-q", "remote", "other")
+	assertEqual(t, os.WriteFile(path.Join(other, "a.md"), []byte("theirs\n"), 0644), nil)
+	runGit(t, other, "rm", "-q", "x.md")
+	runGit(t, other, "commit", "-q", "-am", "theirs")
+	runGit(t, other, "push", "-q")
+
+	assertEqual(t, os.WriteFile(path.Join(local, "a.md"), []byte("mine\n"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(local, "x.md"), []byte("mine x\n"), 0644), nil)
+	return local, other
+}
+
+// pullConflicts commits the local changes and pulls the conflicting changes.
+func pullConflicts(t *testing.T, local string) {
+	t.Helper()
+
+	runGit(t, local, "commit", "-q", "-am", "mine")
+	err := exec.Command("git", "-C", local, "pull", "-q", "--no-rebase", "origin").Run()
+	assertTrue(t, err != nil)
+}
+
+func TestConflictCopyName(t *testing.T) {
+	assertEqual(t, conflictCopyName("k8s/drain.md", "laptop.local"), "k8s/drain.conflict-laptop.md")
+	assertEqual(t, conflictCopyName("a.sh", "my_pc"), "a.conflict-my-pc.sh")
+	assertEqual(t, conflictCopyName("a", ""), "a.conflict-local")
+}
+
+func TestSummarizeChanges(t *testing.T) {
+	changes := []syncChange{
+		{Action: "remove", Name: "old/foo"},
+		{Action: "edit", Name: "git/rebase"},
+		{Action: "add", Name: "k8s/drain"},
+	}
+	assertEqual(t, summarizeChanges(changes, 60), "add k8s/drain, edit git/rebase, remove old/foo")
+	assertEqual(t, summarizeChanges(changes, 30), "add k8s/drain, edit git/rebase and 1 more")
+	assertEqual(t, summarizeChanges(changes, 5), "add k8s/drain and 2 more") // It keeps one name at least.
+	assertEqual(t, summarizeChanges(append(changes, syncChange{Action: "add", Name: "k8s/cordon"}), 60), "add k8s/drain, k8s/cordon, edit git/rebase, remove old/foo")
+	assertEqual(t, summarizeChanges(nil, 60), "update snippets")
+}
+
+func TestSyncMessage(t *testing.T) {
+	repo := t.TempDir()
+	initGitRepo(t, repo)
+	Cfg = &Config{Dir: repo, Git: "git", Profile: "work"}
+	makeTree(t, repo, "a.md", "b.md", "c/d.md")
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "init")
+
+	assertEqual(t, os.WriteFile(path.Join(repo, "a.md"), []byte("changed"), 0644), nil)
+	runGit(t, repo, "mv", "c/d.md", "c/e.md")
+	runGit(t, repo, "rm", "-q", "b.md")
+	makeTree(t, repo, "f.sh")
+	runGit(t, repo, "add", "-A")
+
+	changes, err := stagedChanges()
+	assertEqual(t, err, nil)
+	assertEqual(t, len(changes), 4)
+
+	tmpl, err := parseSyncMessage(defaultSyncMessage)
+	assertEqual(t, err, nil)
+	msg, err := syncMessage(tmpl)
+	assertEqual(t, err, nil)
+	assertEqual(t, msg, "snip: add f.sh, edit a, move c/d to c/e, remove b")
+
+	tmpl, err = parseSyncMessage("{{.Profile}}: {{len .Changes}} changes")
+	assertEqual(t, err, nil)
+	msg, err = syncMessage(tmpl)
+	assertEqual(t, err, nil)
+	assertEqual(t, msg, "work: 4 changes")
+
+	var argErr *argumentError
+	_, err = parseSyncMessage("{{.Summary")
+	assertTrue(t, errors.As(err, &argErr))
+	tmpl, err = parseSyncMessage("{{.Abc}}")
+	assertEqual(t, err, nil)
+	_, err = syncMessage(tmpl)
+	assertTrue(t, errors.As(err, &argErr))
+}
+
+func TestResolveSyncOptions(t *testing.T) {
+	defer func() {
+		FlagSyncRemote, FlagSyncBranch, FlagSyncStrategy, FlagSyncAutostash = "", "", "", false // Reset them.
+	}()
+	local, _ := makeSyncRepos(t)
+	Cfg = &Config{Dir: local, Git: "git", SyncRemote: "origin", SyncStrategy: SyncRebase}
+
+	opts, err := resolveSyncOptions()
+	assertEqual(t, err, nil)
+	assertEqual(t, opts, syncOptions{Remote: "origin", Branch: "main", Strategy: SyncRebase})
+
+	FlagSyncBranch, FlagSyncStrategy = "dev", SyncFFOnly
+	opts, err = resolveSyncOptions()
+	assertEqual(t, err, nil)
+	assertEqual(t, opts, syncOptions{Remote: "origin", Branch: "dev", Strategy: SyncFFOnly})
+
+	var argErr *argumentError
+	FlagSyncAutostash = true
+	opts, err = resolveSyncOptions()
+	assertEqual(t, err, nil)
+	assertTrue(t, opts.Autostash)
+	FlagSyncStrategy = SyncMerge // It commits the changes before the pull.
+	_, err = resolveSyncOptions()
+	assertTrue(t, errors.As(err, &argErr))
+	FlagSyncAutostash = false
+
+	FlagSyncStrategy = "squash"
+	_, err = resolveSyncOptions()
+	assertTrue(t, errors.As(err, &argErr))
+
+	FlagSyncStrategy, FlagSyncRemote = "", "upstream"
+	_, err = resolveSyncOptions()
+	assertTrue(t, errors.As(err, &argErr))
+	assertTrue(t, strings.Contains(err.Error(), "git remote add upstream"))
+
+	// No upstream branch
+	FlagSyncRemote, FlagSyncBranch = "", ""
+	runGit(t, local, "checkout", "-q", "-b", "feature")
+	_, err = resolveSyncOptions()
+	assertTrue(t, errors.As(err, &argErr))
+	assertTrue(t, strings.Contains(err.Error(), "git push -u origin feature"))
+
+	// Detached HEAD
+	runGit(t, local, "checkout", "-q", "--detach")
+	_, err = resolveSyncOptions()
+	assertTrue(t, errors.As(err, &argErr))
+}
+
+func TestSyncOptionsArgs(t *testing.T) {
+	opts := syncOptions{Remote: "origin", Branch: "main", Strategy: SyncMerge}
+	assertEqualSlice(t, opts.pullArgs(), []string{"pull", "--no-rebase", "origin", "main"})
+	assertEqualSlice(t, opts.pushArgs(), []string{"push", "origin", "HEAD:refs/heads/main"})
+
+	opts = syncOptions{Remote: "up", Branch: "dev", Strategy: SyncRebase, Autostash: true}
+	assertEqualSlice(t, opts.pullArgs(), []string{"pull", "--rebase", "--autostash", "up", "dev"})
+
+	opts = syncOptions{Remote: "up", Strategy: SyncFFOnly}
+	assertEqualSlice(t, opts.pullArgs(), []string{"pull", "--ff-only", "up"})
+	assertEqualSlice(t, opts.pushArgs(), []string{"push", "up"})
+}
+
+func TestBeginAndAbortSync(t *testing.T) {
+	local, _ := makeSyncRepos(t)
+	Cfg = &Config{Dir: local, Git: "git"}
+
+	assertTrue(t, errors.Is(abortSync(), os.ErrNotExist))
+
+	state, err := beginSync(syncOptions{Remote: "origin", Branch: "main", Strategy: SyncMerge})
+	assertEqual(t, err, nil)
+	assertEqual(t, state.Head, strings.TrimSpace(runGit(t, local, "rev-parse", "HEAD")))
+	pullConflicts(t, local)
+	state.Commit = strings.TrimSpace(runGit(t, local, "rev-parse", "HEAD"))
+	assertEqual(t, saveSyncState(state), nil)
+	assertEqual(t, pullInProgress(), SyncMerge)
+
+	// It resumes the interrupted sync by its options.
+	resumed, err := beginSync(syncOptions{Remote: "other", Strategy: SyncRebase})
+	assertEqual(t, err, nil)
+	assertEqual(t, resumed.Commit, state.Commit)
+	assertEqual(t, resumed.Options, state.Options)
+
+	files, err := conflictedFiles()
+	assertEqual(t, err, nil)
+	assertEqualSlice(t, files, []string{"a.md", "x.md"})
+	assertEqual(t, resolveConflict("a.md", ResolveBoth, &resumed), nil)
+
+	assertEqual(t, abortSync(), nil)
+	assertEqual(t, pullInProgress(), "")
+	assertEqual(t, strings.TrimSpace(runGit(t, local, "rev-parse", "HEAD")), state.Head)
+	assertEqual(t, runGit(t, local, "status", "--porcelain"), " M a.md\n M x.md\n")
+	_, err = loadSyncState()
+	assertTrue(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestResolveConflicts(t *testing.T) {
+	cases := []struct {
+		tag   string
+		input string
+		a     string // Contents of a.md, empty means it doesn't exist.
+		x     string
+	}{
+		{tag: "mine", input: "m\nm\n", a: "mine\n", x: "mine x\n"},
+		{tag: "theirs", input: "t\nt\n", a: "theirs\n"},
+		{tag: "invalid choices", input: "abc\n\nt\nm\n", a: "theirs\n", x: "mine x\n"},
+		{tag: "edit", input: "e\nm\n", a: "edited\n", x: "mine x\n"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			local, _ := makeSyncRepos(t)
+			Cfg = &Config{Dir: local, Git: "git", EditorCMD: []string{"sh", "-c", `echo edited > "$0"`}}
+			pullConflicts(t, local)
+
+			var out bytes.Buffer
+			assertEqual(t, resolveConflicts(bufio.NewReader(strings.NewReader(c.input)), &out, []string{"a.md", "x.md"}, &syncState{}), nil)
+			assertTrue(t, strings.HasPrefix(out.String(), "Conflict in a\n"))
+
+			for fname, expected := range map[string]string{"a.md": c.a, "x.md": c.x} {
+				b, err := os.ReadFile(path.Join(local, fname))
+				if expected == "" {
+					assertTrue(t, errors.Is(err, os.ErrNotExist))
+					continue
+				}
+				assertEqual(t, string(b), expected)
+			}
+
+			files, err := conflictedFiles()
+			assertEqual(t, err, nil)
+			assertEqual(t, len(files), 0)
+		})
+	}
+}
+
+func TestResolveConflictsBoth(t *testing.T) {
+	local, _ := makeSyncRepos(t)
+	Cfg = &Config{Dir: local, Git: "git"}
+	pullConflicts(t, local)
+	assertEqual(t, saveSyncState(syncState{}), nil)
+
+	host, _ := os.Hostname()
+	var state syncState
+	assertEqual(t, resolveConflicts(bufio.NewReader(strings.NewReader("b\n")), &bytes.Buffer{}, []string{"a.md"}, &state), nil)
+	assertEqualSlice(t, state.Created, []string{conflictCopyName("a.md", host)})
+
+	b, _ := os.ReadFile(path.Join(local, "a.md"))
+	assertEqual(t, string(b), "theirs\n")
+	b, _ = os.ReadFile(path.Join(local, conflictCopyName("a.md", host)))
+	assertEqual(t, string(b), "mine\n")
+
+	// Quit
+	err := resolveConflicts(bufio.NewReader(strings.NewReader("q\n")), &bytes.Buffer{}, []string{"x.md"}, &state)
+	assertEqual(t, err, errSyncQuit)
+}
+
+func TestResolveConflictMarkers(t *testing.T) {
+	local, _ := makeSyncRepos(t)
+	Cfg = &Config{Dir: local, Git: "git", EditorCMD: []string{"true"}} // It doesn't touch the file.
+	pullConflicts(t, local)
+
+	var argErr *argumentError
+	assertTrue(t, errors.As(resolveConflict("a.md", ResolveEdit, &syncState{}), &argErr))
+	assertTrue(t, errors.As(resolveConflict("a.md", "abc", &syncState{}), &argErr))
+}
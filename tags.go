@@ -0,0 +1,131 @@
+package main
+
+import (
+	"cmp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	hashtagRegex  = regexp.MustCompile(`(?:^|\s)#(\p{L}[\p{L}\p{N}_/-]*)`)
+	codeSpanRegex = regexp.MustCompile("`[^`]*`")
+)
+
+type tagCount struct {
+	Tag   string `json:"tag" yaml:"tag"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+// snippetTags returns lower-cased and de-duplicated tags of a snippet. Tags are read from the front-matter
+// and inline #hashtags of markdown snippets.
+func snippetTags(meta Snippet, body []byte, markdown bool) []string {
+	tags := slices.Clone([]string(meta.Tags))
+	if markdown {
+		tags = append(tags, markdownHashtags(string(body))...)
+	}
+
+	var res []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(res, tag) {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
+
+// markdownHashtags returns #hashtags of a markdown text. It ignores code blocks and code spans.
+func markdownHashtags(text string) []string {
+	var res []string
+	inCode := false
+	for _, line := range strings.Split(text, "\n") {
+		if isFence(line) {
+			inCode = !inCode
+			continue
+		}
+
+		if inCode {
+			continue
+		}
+
+		line = codeSpanRegex.ReplaceAllString(line, "")
+		for _, m := range hashtagRegex.FindAllStringSubmatch(line, -1) {
+			res = append(res, strings.TrimRight(m[1], "/-"))
+		}
+	}
+	return res
+}
+
+// readSnippetTags reads tags of a snippet file.
+func readSnippetTags(fpath string) ([]string, error) {
+	b, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, body := parseSnippetLenient(fpath, b)
+	return snippetTags(meta, body, isMarkdown(fpath)), nil
+}
+
+// loadSnippetsTags returns tags of the snippets keyed by the snippets keys. It uses the index if it's
+// enabled, otherwise reads the snippets.
+func loadSnippetsTags(keys []string) (map[string][]string, error) {
+	var idx *Index
+	if Cfg.IndexFile != "" {
+		var err error
+		if idx, err = loadIndex(true); err != nil {
+			Verbose("can not load the index, read tags from the snippets. err: ", err)
+			idx = nil
+		}
+	}
+
+	res := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		if e := idx.entry(key); e != nil && e.Terms != nil {
+			res[key] = e.Tags
+			continue
+		}
+
+		tags, err := readSnippetTags(filepath.Join(Cfg.Dir, filepath.FromSlash(key)))
+		if err != nil {
+			return nil, err
+		}
+		res[key] = tags
+	}
+	return res, nil
+}
+
+// countTags returns the tags sorted by their number of snippets.
+func countTags(snippetsTags map[string][]string) []tagCount {
+	counts := map[string]int{}
+	for _, tags := range snippetsTags {
+		for _, tag := range tags {
+			counts[tag]++
+		}
+	}
+
+	res := make([]tagCount, 0, len(counts))
+	for tag, count := range counts {
+		res = append(res, tagCount{Tag: tag, Count: count})
+	}
+	slices.SortFunc(res, func(a, b tagCount) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+	return res
+}
+
+// hasTags reports whether the snippet tags include all the given tags.
+func hasTags(snippetTags []string, tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(snippetTags, strings.ToLower(tag)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestMarkdownHashtags(t *testing.T) {
+	text := "# Title\n#k8s and #Debug, #incident-response. `#code` a#b\n```bash\n#not-tag\n```\n#123 #a/b/"
+	assertEqualSlice(t, markdownHashtags(text), []string{"k8s", "Debug", "incident-response", "a/b"})
+}
+
+func TestSnippetTags(t *testing.T) {
+	meta := Snippet{Tags: stringList{"K8s", "ops"}}
+	assertEqualSlice(t, snippetTags(meta, []byte("#k8s #debug"), true), []string{"k8s", "ops", "debug"})
+	assertEqualSlice(t, snippetTags(meta, []byte("#k8s #debug"), false), []string{"k8s", "ops"})
+}
+
+func TestLoadSnippetsTags(t *testing.T) {
+	defer resetConfig()
+	dir := t.TempDir()
+	Cfg = &Config{Dir: dir}
+	makeTree(t, dir, "a.md", "b/c.md", "d.sh")
+	assertEqual(t, os.WriteFile(path.Join(dir, "a.md"), []byte("---\ntags: [k8s]\n---\n#debug"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, "b/c.md"), []byte("#k8s"), 0644), nil)
+	assertEqual(t, os.WriteFile(path.Join(dir, "d.sh"), []byte("#k8s"), 0644), nil)
+
+	keys := []string{"a.md", "b/c.md", "d.sh"}
+	for _, indexFile := range []string{"", path.Join(t.TempDir(), "index.json")} {
+		Cfg.IndexFile = indexFile
+		tags, err := loadSnippetsTags(keys)
+		assertEqual(t, err, nil)
+		assertEqualSlice(t, tags["a.md"], []string{"k8s", "debug"})
+		assertEqualSlice(t, tags["b/c.md"], []string{"k8s"})
+		assertEqual(t, len(tags["d.sh"]), 0)
+
+		counts := countTags(tags)
+		assertEqualSlice(t, counts, []tagCount{{Tag: "k8s", Count: 2}, {Tag: "debug", Count: 1}})
+	}
+
+	_, err := loadSnippetsTags([]string{"x.md"})
+	assertTrue(t, err != nil)
+}
+
+func TestHasTags(t *testing.T) {
+	assertTrue(t, hasTags([]string{"a", "b"}, nil))
+	assertTrue(t, hasTags([]string{"a", "b"}, []string{"B", "a"}))
+	assertTrue(t, !hasTags([]string{"a", "b"}, []string{"a", "c"}))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// placeholderRegex matches placeholders like {{name}}, {{name:default}} and {{name:choice1|choice2}}, and also
+// escaped braces (\{{) which are not placeholders, e.g., "\{{name}}" is printed as "{{name}}".
+var placeholderRegex = regexp.MustCompile(`\\\{\{|\{\{\s*([A-Za-z_][\w.-]*)\s*(?::([^{}]*))?\}\}`)
+
+const (
+	escapedBraces = `\{{`
+	rawBlockWord  = "raw" // Fenced code blocks which have this word in their info string have no placeholders.
+)
+
+var FlagUseVars []string
+
+// placeholder is a variable of a snippet template.
+type placeholder struct {
+	Name    string
+	Default string
+	Choices []string // The first choice is the default value.
+}
+
+// mapTemplateText replaces the parts of the text which can have placeholders with the result of fn. Fenced code
+// blocks which opt out of placeholders (e.g., "```yaml raw") are kept untouched.
+func mapTemplateText(text string, fn func(string) string) string {
+	var res, part strings.Builder
+	inCode, raw := false, false
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if isFence(line) {
+			inCode = !inCode
+			if inCode && slices.Contains(strings.Fields(strings.TrimLeft(strings.TrimSpace(line), "`~")), rawBlockWord) {
+				res.WriteString(fn(part.String()))
+				part.Reset()
+				raw = true
+			}
+		}
+
+		if raw {
+			res.WriteString(line)
+		} else {
+			part.WriteString(line)
+		}
+
+		if !inCode {
+			raw = false
+		}
+	}
+	res.WriteString(fn(part.String()))
+	return res.String()
+}
+
+// parsePlaceholders returns placeholders of the text in the order of their first appearance. If a placeholder
+// is repeated, its first definition is used.
+func parsePlaceholders(text string) []placeholder {
+	var matches [][]string
+	mapTemplateText(text, func(s string) string {
+		matches = append(matches, placeholderRegex.FindAllStringSubmatch(s, -1)...)
+		return s
+	})
+
+	var res []placeholder
+	for _, m := range matches {
+		if m[0] == escapedBraces || slices.ContainsFunc(res, func(p placeholder) bool { return p.Name == m[1] }) {
+			continue
+		}
+
+		p := placeholder{Name: m[1], Default: strings.TrimSpace(m[2])}
+		if strings.Contains(m[2], "|") {
+			for _, choice := range strings.Split(m[2], "|") {
+				p.Choices = append(p.Choices, strings.TrimSpace(choice))
+			}
+			p.Default = p.Choices[0]
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+// renderPlaceholders replaces placeholders of the text with their values. Placeholders which don't have any
+// value and escaped braces are kept untouched, so the result is still a template.
+func renderPlaceholders(text string, values map[string]string) string {
+	return mapTemplateText(text, func(s string) string {
+		return placeholderRegex.ReplaceAllStringFunc(s, func(s string) string {
+			if v, ok := values[placeholderRegex.FindStringSubmatch(s)[1]]; ok && s != escapedBraces {
+				return v
+			}
+			return s
+		})
+	})
+}
+
+// unescapePlaceholders replaces escaped braces of the text with literal braces.
+func unescapePlaceholders(text string) string {
+	return mapTemplateText(text, func(s string) string {
+		return strings.ReplaceAll(s, escapedBraces, "{{")
+	})
+}
+
+// readSnippetTemplate reads the snippet and returns its body which placeholders are parsed in.
+func readSnippetTemplate(name string) (string, error) {
+	fpath := Cfg.SnippetPath(name)
+	b, err := os.ReadFile(fpath)
+	if err != nil {
+		return "", err
+	}
+
+	_, body, err := parseSnippet(fpath, b)
+	return string(body), err
+}
+
+// parseVars parses variables in the "key=value" format.
+func parseVars(vars []string) (map[string]string, error) {
+	res := make(map[string]string, len(vars))
+	for _, v := range vars {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, invalidArgument("invalid variable: %q, use the key=value format", v)
+		}
+		res[key] = value
+	}
+	return res, nil
+}
+
+// promptPlaceholders asks values of the placeholders which don't have any value yet. If the input reaches
+// the EOF, default values will be used.
+func promptPlaceholders(r *bufio.Reader, w io.Writer, placeholders []placeholder, values map[string]string) error {
+	for _, p := range placeholders {
+		if _, ok := values[p.Name]; ok {
+			continue
+		}
+
+		msg := p.Name
+		if len(p.Choices) != 0 {
+			msg += " (" + strings.Join(p.Choices, "/") + ")"
+		}
+		if p.Default != "" {
+			msg += " [" + p.Default + "]"
+		}
+
+		for {
+			v, err := StringPrompt(r, w, msg+": ")
+			if err == io.EOF && p.Default != "" {
+				v, err = "", nil
+			}
+			if err != nil {
+				return fmt.Errorf("can not read value of the %s placeholder: %w", p.Name, err)
+			}
+
+			v = DefaultStr(v, p.Default)
+			if len(p.Choices) == 0 || slices.Contains(p.Choices, v) {
+				values[p.Name] = v
+				break
+			}
+
+			if _, err := fmt.Fprintf(w, "invalid value, choose one of: %s\n", strings.Join(p.Choices, ", ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParsePlaceholders(t *testing.T) {
+	text := "kubectl -n {{ namespace:default }} logs {{pod}} -o {{format:json|yaml}} {{pod:other}} {{ 1bad }}"
+	res := parsePlaceholders(text)
+
+	assertEqual(t, len(res), 3)
+	assertEqual(t, res[0].Name, "namespace")
+	assertEqual(t, res[0].Default, "default")
+	assertEqual(t, res[1].Name, "pod")
+	assertEqual(t, res[1].Default, "")
+	assertEqual(t, res[2].Name, "format")
+	assertEqual(t, res[2].Default, "json")
+	assertEqualSlice(t, res[2].Choices, []string{"json", "yaml"})
+}
+
+func TestRenderPlaceholders(t *testing.T) {
+	text := "echo {{a}} {{ b:x }} {{a}} {{c}}"
+	res := renderPlaceholders(text, map[string]string{"a": "1", "b": "2"})
+	assertEqual(t, res, "echo 1 2 1 {{c}}")
+}
+
+func TestPlaceholdersEscape(t *testing.T) {
+	text := "echo \\{{a}} {{a}} {{b}}\n```yaml raw\nv: {{ .Values.a }} {{b}} \\{{c}}\n```\n{{c}}\n"
+	res := parsePlaceholders(text)
+	assertEqual(t, len(res), 3)
+	assertEqual(t, res[0].Name, "a")
+	assertEqual(t, res[1].Name, "b")
+	assertEqual(t, res[2].Name, "c")
+
+	// Escaped braces are kept until the text is printed.
+	text = renderPlaceholders(text, map[string]string{"a": "1", "b": "2", "c": "3"})
+	assertEqual(t, text, "echo \\{{a}} 1 2\n```yaml raw\nv: {{ .Values.a }} {{b}} \\{{c}}\n```\n3\n")
+	assertEqual(t, unescapePlaceholders(text), "echo {{a}} 1 2\n```yaml raw\nv: {{ .Values.a }} {{b}} \\{{c}}\n```\n3\n")
+}
+
+func TestParseVars(t *testing.T) {
+	res, err := parseVars([]string{"a=1", "b=x=y", "c="})
+	assertEqual(t, err, nil)
+	assertEqual(t, res["a"], "1")
+	assertEqual(t, res["b"], "x=y")
+	assertEqual(t, res["c"], "")
+
+	_, err = parseVars([]string{"a"})
+	assertTrue(t, err != nil)
+	_, err = parseVars([]string{"=a"})
+	assertTrue(t, err != nil)
+}
+
+func TestPromptPlaceholders(t *testing.T) {
+	placeholders := parsePlaceholders("{{a}} {{b:def}} {{c:x|y}} {{d}}")
+	values := map[string]string{"d": "given"}
+
+	var w bytes.Buffer
+	r := bufio.NewReader(strings.NewReader("val\n\nz\ny\n"))
+	assertEqual(t, promptPlaceholders(r, &w, placeholders, values), nil)
+
+	assertEqual(t, values["a"], "val")
+	assertEqual(t, values["b"], "def")
+	assertEqual(t, values["c"], "y")
+	assertEqual(t, values["d"], "given")
+	assertEqual(t, w.String(), "a: b [def]: c (x/y) [x]: invalid value, choose one of: x, y\nc (x/y) [x]: ")
+
+	// Defaults are used at EOF, but placeholders without default can not be filled.
+	values = map[string]string{}
+	r = bufio.NewReader(strings.NewReader(""))
+	assertEqual(t, promptPlaceholders(r, &w, placeholders[1:3], values), nil)
+	assertEqual(t, values["b"], "def")
+	assertEqual(t, values["c"], "x")
+	assertTrue(t, promptPlaceholders(r, &w, placeholders[:1], values) != nil)
+}
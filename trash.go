@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	trashMetaFile       = "meta.json"
+	trashItemFile       = "item" // The removed file or directory in the trash item dir.
+	trashItemTimeFormat = "20060102-150405.000000"
+)
+
+var (
+	FlagRemoveYes      = false
+	FlagTrashOlderThan = ""
+	FlagForceRestore   = false
+)
+
+// trashItem is a removed snippet file or directory. Each item is a directory in the trash dir which contains
+// the removed file or directory and the item's metadata.
+type trashItem struct {
+	ID        string    `json:"-"`
+	Path      string    `json:"path"` // Slash-separated original path relative to the snippets dir.
+	IsDir     bool      `json:"is_dir"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (t trashItem) dir() string {
+	return filepath.Join(Cfg.TrashDir, t.ID)
+}
+
+// moveToTrash moves the snippet file or directory (key is relative to the snippets dir) to the trash.
+func moveToTrash(key string, now time.Time) (trashItem, error) {
+	fpath := filepath.Join(Cfg.Dir, filepath.FromSlash(key))
+	info, err := os.Stat(fpath)
+	if err != nil {
+		return trashItem{}, err
+	}
+
+	item := trashItem{
+		ID:        now.UTC().Format(trashItemTimeFormat) + "-" + strings.ReplaceAll(key, "/", "_"),
+		Path:      key,
+		IsDir:     info.IsDir(),
+		DeletedAt: now.UTC(),
+	}
+	if err := os.MkdirAll(item.dir(), 0700); err != nil {
+		return trashItem{}, fmt.Errorf("can not create the trash dir: %w", err)
+	}
+
+	meta, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return trashItem{}, err
+	}
+	if err := os.WriteFile(filepath.Join(item.dir(), trashMetaFile), meta, 0600); err != nil {
+		return trashItem{}, err
+	}
+
+	if err := movePath(fpath, filepath.Join(item.dir(), trashItemFile)); err != nil {
+		_ = os.RemoveAll(item.dir())
+		return trashItem{}, err
+	}
+	return item, nil
+}
+
+// readTrashItem reads the item's metadata.
+func readTrashItem(id string) (trashItem, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return trashItem{}, invalidArgument("invalid trash item: %q", id)
+	}
+
+	b, err := os.ReadFile(filepath.Join(Cfg.TrashDir, id, trashMetaFile))
+	if err != nil {
+		return trashItem{}, fmt.Errorf("trash item %s: %w", id, err)
+	}
+
+	item := trashItem{ID: id}
+	if err := json.Unmarshal(b, &item); err != nil {
+		return trashItem{}, fmt.Errorf("invalid trash item %s: %w", id, err)
+	}
+	return item, nil
+}
+
+// listTrash returns items of the trash, newest first.
+func listTrash() ([]trashItem, error) {
+	entries, err := os.ReadDir(Cfg.TrashDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var res []trashItem
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		item, err := readTrashItem(e.Name())
+		if err != nil {
+			Verbose("skip the trash item: ", err)
+			continue
+		}
+		res = append(res, item)
+	}
+
+	slices.SortStableFunc(res, func(a, b trashItem) int {
+		if c := b.DeletedAt.Compare(a.DeletedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return res, nil
+}
+
+// restoreTrashItem moves the item back to its original path. If force is true, it moves the existing file or
+// directory of the original path to the trash, so it can be restored too.
+func restoreTrashItem(id string, force bool) (trashItem, error) {
+	item, err := readTrashItem(id)
+	if err != nil {
+		return trashItem{}, err
+	}
+
+	fpath := filepath.Join(Cfg.Dir, filepath.FromSlash(item.Path))
+	var replaced *trashItem
+	if _, err := os.Stat(fpath); err == nil {
+		if !force {
+			return trashItem{}, fmt.Errorf("%s: %w, use --force to overwrite it", item.Path, os.ErrExist)
+		}
+		current, err := moveToTrash(item.Path, time.Now())
+		if err != nil {
+			return trashItem{}, err
+		}
+		replaced = &current
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fpath), 0777); err != nil {
+		return trashItem{}, err
+	}
+	if err := movePath(filepath.Join(item.dir(), trashItemFile), fpath); err != nil {
+		if replaced != nil {
+			_, _ = restoreTrashItem(replaced.ID, false) // Put the current one back.
+		}
+		return trashItem{}, err
+	}
+	return item, os.RemoveAll(item.dir())
+}
+
+// emptyTrash permanently removes items which are removed before the time.
+func emptyTrash(before time.Time) ([]trashItem, error) {
+	items, err := listTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []trashItem
+	for _, item := range items {
+		if !item.DeletedAt.Before(before) {
+			continue
+		}
+
+		if err := os.RemoveAll(item.dir()); err != nil {
+			return res, err
+		}
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+// parseAge parses ages like "30d", "2w" or Go durations like "12h".
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v < 0 {
+				return 0, invalidArgument("invalid age: %s", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, invalidArgument("invalid age: %s, use values like 30d, 2w or 12h", s)
+	}
+	return d, nil
+}
+
+// movePath moves a file or directory. If renaming fails (e.g., the destination is on another file system),
+// it copies the source and then removes it.
+func movePath(src string, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if err := copyPath(src, dst); err != nil {
+		_ = os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyPath copies a file or directory recursively.
+func copyPath(src string, dst string) error {
+	return filepath.Walk(src, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, fpath)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		in, err := os.Open(fpath)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			_ = out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestMoveToTrash(t *testing.T) {
+	tmpDir := t.TempDir()
+	Cfg = &Config{Dir: tmpDir, TrashDir: t.TempDir()}
+	makeTree(t, tmpDir, "a/b.md", "a/c/d.md")
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	item, err := moveToTrash("a/b.md", now)
+	assertEqual(t, err, nil)
+	assertEqual(t, item.ID, "20240102-030405.000000-a_b.md")
+	assertExists(t, Cfg.TrashDir, item.ID+"/meta.json", item.ID+"/item")
+	_, err = os.Stat(path.Join(tmpDir, "a/b.md"))
+	assertTrue(t, os.IsNotExist(err))
+
+	res, err := readTrashItem(item.ID)
+	assertEqual(t, err, nil)
+	assertEqual(t, res, item)
+
+	dirItem, err := moveToTrash("a", now.Add(time.Second))
+	assertEqual(t, err, nil)
+	assertTrue(t, dirItem.IsDir)
+	assertExists(t, Cfg.TrashDir, dirItem.ID+"/item/c/d.md")
+
+	_, err = moveToTrash("not-found.md", now)
+	assertTrue(t, os.IsNotExist(err))
+}
+
+func TestListTrash(t *testing.T) {
+	Cfg = &Config{Dir: t.TempDir(), TrashDir: path.Join(t.TempDir(), "trash")}
+	items, err := listTrash()
+	assertEqual(t, err, nil)
+	assertEqual(t, len(items), 0)
+
+	makeTree(t, Cfg.Dir, "a.md", "b.md")
+	now := time.Now()
+	_, err = moveToTrash("a.md", now.Add(-time.Hour))
+	assertEqual(t, err, nil)
+	_, err = moveToTrash("b.md", now)
+	assertEqual(t, err, nil)
+	makeTree(t, Cfg.TrashDir, "invalid/")
+
+	items, err = listTrash()
+	assertEqual(t, err, nil)
+	assertEqual(t, len(items), 2)
+	assertEqual(t, items[0].Path, "b.md")
+	assertEqual(t, items[1].Path, "a.md")
+}
+
+func TestRestoreTrashItem(t *testing.T) {
+	Cfg = &Config{Dir: t.TempDir(), TrashDir: t.TempDir()}
+	makeTree(t, Cfg.Dir, "a/b/c.md")
+
+	item, err := moveToTrash("a/b", time.Now())
+	assertEqual(t, err, nil)
+
+	res, err := restoreTrashItem(item.ID, false)
+	assertEqual(t, err, nil)
+	assertEqual(t, res.Path, "a/b")
+	assertExists(t, Cfg.Dir, "a/b/c.md")
+	_, err = os.Stat(path.Join(Cfg.TrashDir, item.ID))
+	assertTrue(t, os.IsNotExist(err))
+
+	// The current snippet is moved to the trash instead of being overwritten.
+	item, err = moveToTrash("a/b/c.md", time.Now())
+	assertEqual(t, err, nil)
+	makeTree(t, Cfg.Dir, "a/b/c.md")
+	_, err = restoreTrashItem(item.ID, false)
+	assertTrue(t, errors.Is(err, os.ErrExist))
+	_, err = restoreTrashItem(item.ID, true)
+	assertEqual(t, err, nil)
+	items, err := listTrash()
+	assertEqual(t, err, nil)
+	assertEqual(t, len(items), 1)
+	assertEqual(t, items[0].Path, "a/b/c.md")
+	assertTrue(t, items[0].ID != item.ID)
+
+	_, err = restoreTrashItem("../x", false)
+	assertTrue(t, err != nil)
+	_, err = restoreTrashItem("not-found", false)
+	assertTrue(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestEmptyTrash(t *testing.T) {
+	Cfg = &Config{Dir: t.TempDir(), TrashDir: t.TempDir()}
+	makeTree(t, Cfg.Dir, "a.md", "b.md")
+
+	now := time.Now()
+	_, err := moveToTrash("a.md", now.Add(-48*time.Hour))
+	assertEqual(t, err, nil)
+	_, err = moveToTrash("b.md", now)
+	assertEqual(t, err, nil)
+
+	removed, err := emptyTrash(now.Add(-24 * time.Hour))
+	assertEqual(t, err, nil)
+	assertEqual(t, len(removed), 1)
+	assertEqual(t, removed[0].Path, "a.md")
+
+	items, _ := listTrash()
+	assertEqual(t, len(items), 1)
+	assertEqual(t, items[0].Path, "b.md")
+}
+
+func TestParseAge(t *testing.T) {
+	cases := []struct {
+		tag   string
+		age   string
+		res   time.Duration
+		isErr bool
+	}{
+		{tag: "t1", age: "30d", res: 30 * 24 * time.Hour},
+		{tag: "t2", age: "2w", res: 14 * 24 * time.Hour},
+		{tag: "t3", age: "12h", res: 12 * time.Hour},
+		{tag: "t4", age: "-1d", isErr: true},
+		{tag: "t5", age: "xd", isErr: true},
+		{tag: "t6", age: "abc", isErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tag, func(t *testing.T) {
+			res, err := parseAge(c.age)
+			assertEqual(t, res, c.res)
+			assertEqual(t, err != nil, c.isErr)
+		})
+	}
+}
+
+func TestCopyPath(t *testing.T) {
+	src, dst := t.TempDir(), path.Join(t.TempDir(), "dst")
+	makeTree(t, src, "a.md", "b/c.md")
+
+	assertEqual(t, copyPath(src, dst), nil)
+	assertExists(t, dst, "a.md", "b/c.md")
+	assertExists(t, src, "a.md", "b/c.md")
+}
@@ -112,6 +112,15 @@ func EndsWithDirectoryPath(path string) bool {
 }
 
 func BoolPrompt(r io.Reader, w io.Writer, msg string) (bool, error) {
+	return boolPrompt(r, w, msg, true)
+}
+
+// ConfirmPrompt is like BoolPrompt, but an empty answer means no. It confirms destructive actions.
+func ConfirmPrompt(r io.Reader, w io.Writer, msg string) (bool, error) {
+	return boolPrompt(r, w, msg, false)
+}
+
+func boolPrompt(r io.Reader, w io.Writer, msg string, def bool) (bool, error) {
 	if _, err := fmt.Fprint(w, msg); err != nil {
 		return false, err
 	}
@@ -120,5 +129,30 @@ func BoolPrompt(r io.Reader, w io.Writer, msg string) (bool, error) {
 		return false, err
 	}
 	res := strings.ToLower(string(resBytes[:len(resBytes)-1]))
-	return res == "" || res == "y" || res == "yes", nil
+	return (res == "" && def) || res == "y" || res == "yes", nil
+}
+
+// StringPrompt prints the message and returns the user's input line. To read multiple lines from a reader,
+// wrap it by a bufio.Reader once and pass it to all prompts, so buffered input doesn't get lost.
+func StringPrompt(r io.Reader, w io.Writer, msg string) (string, error) {
+	if _, err := fmt.Fprint(w, msg); err != nil {
+		return "", err
+	}
+	res, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && (err != io.EOF || res == "") {
+		return "", err
+	}
+	return strings.TrimRight(res, "\r\n"), nil
+}
+
+// IsTerminal reports whether the file is a terminal (character device).
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// openTerminal opens the controlling terminal. We use it when stdin/stdout are redirected but we still need
+// to talk to the user (e.g., to ask for confirmation). It's a variable to be replaceable in tests.
+var openTerminal = func() (io.ReadWriteCloser, error) {
+	return os.OpenFile("/dev/tty", os.O_RDWR, 0)
 }
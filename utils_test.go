@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -39,6 +40,30 @@ func assertTrue(t *testing.T, isTrue bool, args ...any) {
 	}
 }
 
+type fakeTTY struct {
+	io.Reader
+	io.Writer
+}
+
+func (fakeTTY) Close() error {
+	return nil
+}
+
+// fakeTerminal replaces the terminal by a fake terminal which reads the input and writes to the returned buffer.
+func fakeTerminal(t *testing.T, input string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	old := openTerminal
+	openTerminal = func() (io.ReadWriteCloser, error) {
+		return fakeTTY{Reader: strings.NewReader(input), Writer: &buf}, nil
+	}
+	t.Cleanup(func() {
+		openTerminal = old
+	})
+	return &buf
+}
+
 func TestCommand(t *testing.T) {
 	t.Helper()
 	cmd := Command("a", "b")
@@ -139,4 +164,29 @@ func TestBoolPrompt(t *testing.T) {
 		})
 	}
 
+	// An empty answer is yes, except when confirming destructive actions.
+	ok, err := BoolPrompt(bytes.NewBufferString("\n"), io.Discard, "abc")
+	assertEqual(t, err, nil)
+	assertTrue(t, ok)
+	ok, err = ConfirmPrompt(bytes.NewBufferString("\n"), io.Discard, "abc")
+	assertEqual(t, err, nil)
+	assertTrue(t, !ok)
+	ok, err = ConfirmPrompt(bytes.NewBufferString("y\n"), io.Discard, "abc")
+	assertEqual(t, err, nil)
+	assertTrue(t, ok)
+}
+
+func TestStringPrompt(t *testing.T) {
+	var w bytes.Buffer
+	res, err := StringPrompt(bytes.NewBufferString("abc def\r\nnext\n"), &w, "msg: ")
+	assertEqual(t, err, nil)
+	assertEqual(t, res, "abc def")
+	assertEqual(t, w.String(), "msg: ")
+
+	res, err = StringPrompt(bytes.NewBufferString("last"), &w, "")
+	assertEqual(t, err, nil)
+	assertEqual(t, res, "last")
+
+	_, err = StringPrompt(bytes.NewBufferString(""), &w, "")
+	assertEqual(t, err, io.EOF)
 }